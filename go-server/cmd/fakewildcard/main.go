@@ -0,0 +1,39 @@
+// Command fakewildcard runs the fake Wildcard backend from the wildcardtest
+// package, so the server can be run locally without a Wildcard backend.
+//
+//	go run ./cmd/fakewildcard -addr :8000 -script script.json
+//
+// The script is a JSON list of Wildcard responses, e.g.
+//
+//	[{"event": "EXEC", "api": "stripe", "data": {"name": "stripe_get_customers", "arguments": {"limit": 3}}},
+//	 {"event": "STOP", "data": {"message": "Listed the customers"}}]
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/wildcardtest"
+)
+
+func main() {
+	addr := flag.String("addr", ":8000", "address to listen on")
+	scriptPath := flag.String("script", "", "JSON file with the responses to play (defaults to a single STOP)")
+	flag.Parse()
+
+	script := []wildcard.Response{wildcardtest.Stop(map[string]interface{}{"message": "Done"})}
+	if *scriptPath != "" {
+		loaded, err := wildcardtest.LoadScript(*scriptPath)
+		if err != nil {
+			log.Fatalf("Failed to load script: %v", err)
+		}
+		script = loaded
+	}
+
+	log.Printf("Fake Wildcard backend listening on %s with %d scripted responses", *addr, len(script))
+	if err := http.ListenAndServe(*addr, wildcardtest.NewBackend(script...)); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
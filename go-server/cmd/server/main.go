@@ -3,11 +3,18 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 
+	"github.com/openai/openai-go/option"
+	"github.com/wildcard-lovable/go-server/internal/cassette"
 	"github.com/wildcard-lovable/go-server/internal/config"
 	"github.com/wildcard-lovable/go-server/internal/handlers"
 	"github.com/wildcard-lovable/go-server/internal/middleware"
 	"github.com/wildcard-lovable/go-server/internal/services"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/github"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/openapi"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/slack"
 	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/stripe"
 )
 
@@ -15,19 +22,84 @@ func main() {
 	// Load configuration
 	cfg := config.NewConfig()
 
+	// Record or replay outbound requests if configured
+	recorder, err := newRecorder(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up cassette: %v", err)
+	}
+	var openaiOptions []option.RequestOption
+	if recorder != nil {
+		stripe.UseHTTPClient(recorder.Client())
+		openaiOptions = append(openaiOptions, option.WithHTTPClient(recorder.Client()))
+	}
+
 	// Initialize services
-	stripeStore := services.NewStripeKeyStore()
-	stripeExecutor := stripe.NewExecutor(stripeStore)
-	openaiService := services.NewOpenAIService(cfg.OpenAIAPIKey)
-	processor := services.NewProcessor(cfg.WildcardBackendURL, stripeExecutor, openaiService)
+	keyStore := services.NewKeyStore()
+	stripeExecutor := stripe.NewExecutor(keyStore.Stripe())
+	githubExecutor := github.NewExecutor(keyStore.For(wildcard.APINameGitHub), cfg.GitHubAPIURL)
+	slackExecutor := slack.NewExecutor(keyStore.For(wildcard.APINameSlack), cfg.SlackAPIURL)
+	if recorder != nil {
+		githubExecutor.SetHTTPClient(recorder.Client())
+		slackExecutor.SetHTTPClient(recorder.Client())
+	}
+	openaiService := services.NewOpenAIService(cfg.OpenAIAPIKey, openaiOptions...)
+	registry := wildcard.NewRegistry()
+	registry.Register(wildcard.APINameStripe, stripeExecutor)
+	registry.Register(wildcard.APINameGitHub, githubExecutor)
+	registry.Register(wildcard.APINameSlack, slackExecutor)
+	processor := services.NewProcessor(cfg.WildcardBackendURL, registry, openaiService)
+	processor.SetKeyStore(keyStore)
+	if cfg.PIIRedaction {
+		processor.SetRedactor(wildcard.NewRedactor(cfg.PIIFields))
+	}
+	if recorder != nil {
+		processor.SetRecorder(recorder, cfg.CassetteDir)
+	}
+
+	// Register the integrations described by OpenAPI documents
+	if cfg.OpenAPIIntegrations != "" {
+		configs, err := openapi.LoadConfigs(cfg.OpenAPIIntegrations)
+		if err != nil {
+			log.Fatalf("Failed to load OpenAPI integrations: %v", err)
+		}
+		for _, c := range configs {
+			executor, err := openapi.New(c, keyStore.For(c.API))
+			if err != nil {
+				log.Fatalf("Failed to load OpenAPI integration %s: %v", c.API, err)
+			}
+			if recorder != nil {
+				if name := executor.CredentialQueryParam(); name != "" {
+					recorder.RedactQuery(name)
+				}
+				executor.SetHTTPClient(recorder.Client())
+			}
+			executor.Register(processor.Client())
+			log.Printf("Registered %s with %d functions from %s", c.API, len(executor.Operations()), c.Document)
+		}
+	}
 
 	// Initialize handler
-	messageHandler := handlers.NewMessageHandler(processor, stripeStore)
+	messageHandler := handlers.NewMessageHandler(processor, keyStore)
+	integrationsHandler := handlers.NewIntegrationsHandler(registry)
 
 	// Set up routes with CORS middleware
 	http.HandleFunc("/process", middleware.CorsMiddleware(messageHandler.ProcessMessage))
 	http.HandleFunc("/process-stream", middleware.CorsMiddleware(messageHandler.StreamProcess))
 	http.HandleFunc("/register-stripe", middleware.CorsMiddleware(messageHandler.HandleStripeRegistration))
+	http.HandleFunc("/register-key", middleware.CorsMiddleware(messageHandler.HandleKeyRegistration))
+	http.HandleFunc("/integrations", middleware.CorsMiddleware(integrationsHandler.ListIntegrations))
+	http.HandleFunc("/integrations/", middleware.CorsMiddleware(integrationsHandler.ListFunctions))
+
+	if connectCfg := cfg.StripeConnect; connectCfg.ClientID != "" {
+		connect := services.NewStripeConnect(connectCfg.ClientID, connectCfg.SecretKey, connectCfg.RedirectURL, connectCfg.URL)
+		keyStore.SetRefresher(wildcard.APINameStripe, connect.Refresh)
+		// The token exchange is not recorded: it carries the platform's secret
+		// key and the connected account's tokens
+		oauthHandler := handlers.NewOAuthHandler(connect, keyStore)
+		http.HandleFunc("/oauth/stripe/start", middleware.CorsMiddleware(oauthHandler.StartStripe))
+		http.HandleFunc("/oauth/stripe/callback", middleware.CorsMiddleware(oauthHandler.StripeCallback))
+		http.HandleFunc("/oauth/stripe/revoke", middleware.CorsMiddleware(oauthHandler.RevokeStripe))
+	}
 
 	// Start server
 	log.Printf("Starting server on port %s", cfg.Port)
@@ -35,3 +107,26 @@ func main() {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// newRecorder replays the cassette at CASSETTE_REPLAY, or records each run to
+// CASSETTE_DIR. It returns nil if neither is set.
+func newRecorder(cfg *config.Config) (*cassette.Recorder, error) {
+	switch {
+	case cfg.CassetteReplay != "":
+		c, err := cassette.Load(cfg.CassetteReplay)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Replaying %d recorded exchanges from %s", len(c.Interactions), cfg.CassetteReplay)
+		return cassette.NewReplayer(c), nil
+	case cfg.CassetteDir != "":
+		// Cassettes hold unredacted request and response bodies
+		if err := os.MkdirAll(cfg.CassetteDir, 0o700); err != nil {
+			return nil, err
+		}
+		log.Printf("Recording runs to %s. Cassettes contain unredacted PII from requests and responses.", cfg.CassetteDir)
+		return cassette.NewRecorder(nil), nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,256 @@
+// Package cassette records the outbound HTTP exchanges of agent runs and
+// replays them, so a run can be reproduced without Wildcard, OpenAI or Stripe.
+// Bodies are recorded verbatim, so cassettes contain whatever PII the run sent
+// or received.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// Request is the recorded part of an outbound request. Headers are not recorded
+// since they carry credentials, and neither are the values of query parameters
+// passed to RedactQuery.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Interaction is a single recorded exchange
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the list of exchanges of a run, in order
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load reads a cassette from a file
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette: %w", err)
+	}
+	return &c, nil
+}
+
+// Save writes a cassette to a file
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// Recorder is an http.RoundTripper that either records exchanges passed on to
+// an underlying transport, or replays the exchanges of a cassette in order.
+type Recorder struct {
+	mu        sync.Mutex
+	replay    bool
+	transport http.RoundTripper
+	cassette  *Cassette
+	next      int
+	redacted  map[string]bool
+}
+
+// NewRecorder creates a recorder that sends requests through transport, or
+// http.DefaultTransport if nil
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{
+		transport: transport,
+		cassette:  &Cassette{},
+	}
+}
+
+// NewReplayer creates a recorder that serves the exchanges of a cassette. Each
+// request must match the method, URL and body of the next recorded request.
+func NewReplayer(c *Cassette) *Recorder {
+	return &Recorder{
+		replay:   true,
+		cassette: c,
+	}
+}
+
+// RedactQuery replaces the values of the named query parameters with
+// "REDACTED" in recorded URLs, for APIs that take their credential in the
+// query string. A replayer must redact the same parameters to match them.
+func (r *Recorder) RedactQuery(names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.redacted == nil {
+		r.redacted = map[string]bool{}
+	}
+	for _, name := range names {
+		r.redacted[name] = true
+	}
+}
+
+// Client returns an HTTP client using the recorder as its transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Begin starts a new run: a recorder discards what it recorded so far, and a
+// replayer rewinds to the start of its cassette
+func (r *Recorder) Begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next = 0
+	if !r.replay {
+		r.cassette = &Cassette{}
+	}
+}
+
+// Cassette returns the exchanges recorded since the last Begin
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Replaying reports whether the recorder replays a cassette
+func (r *Recorder) Replaying() bool {
+	return r.replay
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := readRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded.URL = r.redactURL(req.URL)
+	if r.replay {
+		return r.replayRequest(req, recorded)
+	}
+	return r.recordRequest(req, recorded)
+}
+
+func (r *Recorder) recordRequest(req *http.Request, recorded Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cassette: failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       string(body),
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replayRequest(req *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.cassette.Interactions) {
+		return nil, fmt.Errorf("cassette: unexpected request %s %s after the last recorded exchange", recorded.Method, recorded.URL)
+	}
+	interaction := r.cassette.Interactions[r.next]
+	expected := interaction.Request
+	if expected.Method != recorded.Method || expected.URL != recorded.URL {
+		return nil, fmt.Errorf("cassette: unexpected request %s %s, expected %s %s", recorded.Method, recorded.URL, expected.Method, expected.URL)
+	}
+	if expected.Body != recorded.Body {
+		return nil, fmt.Errorf("cassette: body of %s %s does not match the recording:\n got: %s\nwant: %s", recorded.Method, recorded.URL, recorded.Body, expected.Body)
+	}
+	r.next++
+
+	header := interaction.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Response.Body))),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// redactURL returns u with the values of redacted query parameters replaced
+func (r *Recorder) redactURL(u *url.URL) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	query := u.Query()
+	redacted := false
+	for name := range query {
+		if r.redacted[name] {
+			query[name] = []string{"REDACTED"}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+	clone := *u
+	clone.RawQuery = query.Encode()
+	return clone.String()
+}
+
+// readRequest captures a request, leaving its body readable for the transport
+func readRequest(req *http.Request) (Request, error) {
+	recorded := Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, fmt.Errorf("cassette: failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	recorded.Body = string(body)
+	return recorded, nil
+}
@@ -0,0 +1,80 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("echo " + string(body)))
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(nil)
+	resp, err := recorder.Client().Post(server.URL+"/echo", "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "echo hello", string(body), "recording passes the response through")
+
+	path := filepath.Join(t.TempDir(), "run.json")
+	require.NoError(t, recorder.Cassette().Save(path))
+	server.Close()
+
+	c, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 1)
+	assert.Equal(t, Request{Method: http.MethodPost, URL: server.URL + "/echo", Body: "hello"}, c.Interactions[0].Request)
+
+	replayer := NewReplayer(c)
+	resp, err = replayer.Client().Post(server.URL+"/echo", "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "echo hello", string(body))
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+
+	_, err = replayer.Client().Post(server.URL+"/echo", "text/plain", strings.NewReader("hello"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "after the last recorded exchange")
+
+	replayer.Begin()
+	_, err = replayer.Client().Post(server.URL+"/echo", "text/plain", strings.NewReader("goodbye"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match the recording")
+	_, err = replayer.Client().Get(server.URL + "/other")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected request GET")
+}
+
+func TestRedactQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Query().Get("api_key")))
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(nil)
+	recorder.RedactQuery("api_key")
+	resp, err := recorder.Client().Get(server.URL + "/pets?api_key=secret_123&limit=2")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "secret_123", string(body), "the credential is still sent")
+
+	c := recorder.Cassette()
+	require.Len(t, c.Interactions, 1)
+	assert.Equal(t, server.URL+"/pets?api_key=REDACTED&limit=2", c.Interactions[0].Request.URL)
+	assert.NotContains(t, c.Interactions[0].Request.URL, "secret_123")
+
+	replayer := NewReplayer(c)
+	replayer.RedactQuery("api_key")
+	_, err = replayer.Client().Get(server.URL + "/pets?api_key=other_key&limit=2")
+	assert.NoError(t, err, "redacted parameters match any value")
+}
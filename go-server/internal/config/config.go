@@ -3,19 +3,50 @@ package config
 import (
 	"log"
 	"os"
+	"strings"
 )
 
 type Config struct {
-	Port               string
-	WildcardBackendURL string
-	OpenAIAPIKey       string
+	Port                string
+	WildcardBackendURL  string
+	OpenAIAPIKey        string
+	PIIRedaction        bool
+	PIIFields           []string
+	CassetteDir         string
+	CassetteReplay      string
+	GitHubAPIURL        string
+	SlackAPIURL         string
+	OpenAPIIntegrations string
+	StripeConnect       StripeConnectConfig
+}
+
+// StripeConnectConfig configures the Stripe Connect OAuth flow. The flow is
+// enabled when ClientID is set.
+type StripeConnectConfig struct {
+	ClientID    string
+	SecretKey   string
+	RedirectURL string
+	URL         string // serves the authorize and token endpoints
 }
 
 func NewConfig() *Config {
 	return &Config{
-		Port:               getEnvOrDefault("PORT", "8080"),
-		WildcardBackendURL: getEnvOrDefault("WILDCARD_BACKEND_URL", "http://localhost:8000"),
-		OpenAIAPIKey:       getEnv("OPENAI_API_KEY"),
+		Port:                getEnvOrDefault("PORT", "8080"),
+		WildcardBackendURL:  getEnvOrDefault("WILDCARD_BACKEND_URL", "http://localhost:8000"),
+		OpenAIAPIKey:        getEnv("OPENAI_API_KEY"),
+		PIIRedaction:        getEnvOrDefault("PII_REDACTION", "true") != "false",
+		PIIFields:           getListEnv("PII_FIELDS"),
+		CassetteDir:         os.Getenv("CASSETTE_DIR"),
+		CassetteReplay:      os.Getenv("CASSETTE_REPLAY"),
+		GitHubAPIURL:        os.Getenv("GITHUB_API_URL"),
+		SlackAPIURL:         os.Getenv("SLACK_API_URL"),
+		OpenAPIIntegrations: os.Getenv("OPENAPI_INTEGRATIONS"),
+		StripeConnect: StripeConnectConfig{
+			ClientID:    os.Getenv("STRIPE_CONNECT_CLIENT_ID"),
+			SecretKey:   os.Getenv("STRIPE_CONNECT_SECRET_KEY"),
+			RedirectURL: os.Getenv("STRIPE_CONNECT_REDIRECT_URL"),
+			URL:         os.Getenv("STRIPE_CONNECT_URL"),
+		},
 	}
 }
 
@@ -34,3 +65,13 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
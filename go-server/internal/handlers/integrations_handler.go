@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// IntegrationsHandler serves the catalog of the registered integrations
+type IntegrationsHandler struct {
+	registry *wildcard.Registry
+}
+
+// NewIntegrationsHandler creates a new integrations handler
+func NewIntegrationsHandler(registry *wildcard.Registry) *IntegrationsHandler {
+	return &IntegrationsHandler{
+		registry: registry,
+	}
+}
+
+// IntegrationSummary describes a registered integration
+type IntegrationSummary struct {
+	API       string `json:"api"`
+	Functions int    `json:"functions"`
+	ReadOnly  int    `json:"read_only"`
+}
+
+// ListIntegrations handles GET /integrations
+func (h *IntegrationsHandler) ListIntegrations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	integrations := []IntegrationSummary{}
+	for _, api := range h.registry.APIs() {
+		catalog, _ := h.registry.Catalog(api)
+		summary := IntegrationSummary{API: api, Functions: len(catalog)}
+		for _, fn := range catalog {
+			if fn.ReadOnly {
+				summary.ReadOnly++
+			}
+		}
+		integrations = append(integrations, summary)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"integrations": integrations})
+}
+
+// ListFunctions handles GET /integrations/{api}/functions
+func (h *IntegrationsHandler) ListFunctions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/integrations/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "functions" {
+		http.NotFound(w, r)
+		return
+	}
+	api := parts[0]
+
+	catalog, ok := h.registry.Catalog(api)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown integration: %s", api), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api":       api,
+		"functions": catalog,
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wildcard-lovable/go-server/internal/services"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/stripe"
+)
+
+func TestIntegrationsCatalog(t *testing.T) {
+	registry := wildcard.NewRegistry()
+	registry.Register(wildcard.APINameStripe, stripe.NewExecutor(services.NewStripeKeyStore()))
+	handler := NewIntegrationsHandler(registry)
+
+	rec := httptest.NewRecorder()
+	handler.ListIntegrations(rec, httptest.NewRequest(http.MethodGet, "/integrations", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Integrations []IntegrationSummary `json:"integrations"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Integrations, 1)
+	assert.Equal(t, wildcard.APINameStripe, list.Integrations[0].API)
+	assert.Greater(t, list.Integrations[0].Functions, list.Integrations[0].ReadOnly)
+	assert.Greater(t, list.Integrations[0].ReadOnly, 0)
+
+	rec = httptest.NewRecorder()
+	handler.ListFunctions(rec, httptest.NewRequest(http.MethodGet, "/integrations/stripe/functions", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var functions struct {
+		API       string                  `json:"api"`
+		Functions []wildcard.FunctionInfo `json:"functions"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &functions))
+	assert.Len(t, functions.Functions, list.Integrations[0].Functions)
+
+	byName := make(map[string]wildcard.FunctionInfo)
+	for _, fn := range functions.Functions {
+		byName[fn.Name] = fn
+	}
+	getCustomer := byName["stripe_get_customers_customer"]
+	assert.True(t, getCustomer.ReadOnly)
+	assert.NotEmpty(t, getCustomer.Description)
+	assert.Equal(t, []interface{}{"customer"}, getCustomer.Arguments["required"])
+	assert.Contains(t, getCustomer.Arguments["properties"], "customer")
+	assert.False(t, byName["stripe_post_customers"].ReadOnly)
+
+	rec = httptest.NewRecorder()
+	handler.ListFunctions(rec, httptest.NewRequest(http.MethodGet, "/integrations/github/functions", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
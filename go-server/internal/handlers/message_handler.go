@@ -4,22 +4,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/wildcard-lovable/go-server/internal/models"
 	"github.com/wildcard-lovable/go-server/internal/services"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
 )
 
 // MessageHandler handles HTTP requests for message processing
 type MessageHandler struct {
-	processor   *services.Processor
-	stripeStore *services.StripeKeyStore
+	processor *services.Processor
+	keyStore  *services.KeyStore
 }
 
 // NewMessageHandler creates a new message handler
-func NewMessageHandler(processor *services.Processor, stripeStore *services.StripeKeyStore) *MessageHandler {
+func NewMessageHandler(processor *services.Processor, keyStore *services.KeyStore) *MessageHandler {
 	return &MessageHandler{
-		processor:   processor,
-		stripeStore: stripeStore,
+		processor: processor,
+		keyStore:  keyStore,
 	}
 }
 
@@ -46,64 +48,70 @@ func (h *MessageHandler) ProcessMessage(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(resp)
 }
 
-// StreamProcess handles SSE streaming of message processing
+// StreamProcess handles SSE streaming of message processing. Every stream
+// ends with a complete or error event, and a heartbeat comment is sent while
+// processing is quiet. Streams cannot be resumed: a reconnect carrying
+// Last-Event-ID gets an error event instead of running the message again.
 func (h *MessageHandler) StreamProcess(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	stream := newSSEStream(w, flusher)
 
-	// Set headers for SSE
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	// Create a channel for updates
-	updates := make(chan models.StreamUpdate)
+	// Runs are not kept once their stream ends, so there is nothing to resume
+	// from, and running the message again would repeat its function calls
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		stream.sendError("Cannot resume stream", fmt.Errorf("resuming after event %s is not supported; send the message as a new request", lastID))
+		return
+	}
 
 	// Parse request
 	var req models.MessageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendSSEError(w, "Failed to decode request", err)
+		stream.sendError("Failed to decode request", err)
 		return
 	}
 
 	// Start processing in a goroutine
+	updates := make(chan models.StreamUpdate)
 	go h.processor.StreamProcessMessage(req.UserID, req.Message, updates)
 
-	// Stream updates to client
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
-		return
-	}
+	heartbeat := time.NewTicker(SSEHeartbeatInterval)
+	defer heartbeat.Stop()
 
 	// Stream updates until done or client disconnects
-	for update := range updates {
-		data, err := json.Marshal(update)
-		if err != nil {
-			sendSSEError(w, "Failed to marshal update", err)
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				if !stream.terminated() {
+					stream.sendError("Processing ended unexpectedly", fmt.Errorf("no final event"))
+				}
+				return
+			}
+			if err := stream.send(update); err != nil {
+				stream.sendError("Failed to marshal update", err)
+				go drain(updates)
+				return
+			}
+		case <-heartbeat.C:
+			stream.heartbeat()
+		case <-r.Context().Done():
+			go drain(updates)
 			return
 		}
-
-		fmt.Fprintf(w, "data: %s\n\n", data)
-		flusher.Flush()
 	}
 }
 
-func sendSSEError(w http.ResponseWriter, msg string, err error) {
-	errUpdate := models.StreamUpdate{
-		Type: "error",
-		Data: map[string]interface{}{
-			"message": msg,
-			"error":   err.Error(),
-		},
-	}
-	data, _ := json.Marshal(errUpdate)
-	fmt.Fprintf(w, "data: %s\n\n", data)
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
+// drain discards the remaining updates so that processing can finish
+func drain(updates <-chan models.StreamUpdate) {
+	for range updates {
 	}
 }
 
@@ -119,7 +127,57 @@ func (h *MessageHandler) HandleStripeRegistration(w http.ResponseWriter, r *http
 		return
 	}
 
-	if err := h.stripeStore.RegisterKey(req.UserID, req.APIKey); err != nil {
+	if err := h.keyStore.Stripe().RegisterKey(req.UserID, req.APIKey); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+type KeyRegistrationRequest struct {
+	UserID  string `json:"userId"`
+	API     string `json:"api"`
+	APIKey  string `json:"apiKey"`
+	Name    string `json:"name,omitempty"`    // registers a named credential instead of the default key
+	Account string `json:"account,omitempty"` // account the key acts on, e.g. a Stripe Connect account
+	Default bool   `json:"default,omitempty"` // selects the named credential as the user's default
+}
+
+// HandleKeyRegistration registers a user's API key for any registered
+// integration. A named credential is added next to the user's other
+// credentials; with default set and no apiKey, an existing named credential
+// is selected as the default.
+func (h *MessageHandler) HandleKeyRegistration(w http.ResponseWriter, r *http.Request) {
+	var req KeyRegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.processor.Registry().Executor(req.API); !ok {
+		http.Error(w, fmt.Sprintf("unknown integration: %s", req.API), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch {
+	case req.Name == "":
+		err = h.keyStore.RegisterKey(req.UserID, req.API, req.APIKey)
+	case req.APIKey == "" && req.Default:
+		err = h.keyStore.SetDefault(req.UserID, req.API, req.Name)
+	default:
+		err = h.keyStore.RegisterCredential(req.UserID, req.API, wildcard.Credential{
+			Name:    req.Name,
+			Secret:  req.APIKey,
+			Account: req.Account,
+		})
+		if err == nil && req.Default {
+			err = h.keyStore.SetDefault(req.UserID, req.API, req.Name)
+		}
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	stripeapi "github.com/stripe/stripe-go/v81"
+	"github.com/wildcard-lovable/go-server/internal/models"
+	"github.com/wildcard-lovable/go-server/internal/services"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/stripe"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/wildcardtest"
+)
+
+// newFakeOpenAI serves chat completions with the given replies, in order
+func newFakeOpenAI(t *testing.T, replies ...string) *httptest.Server {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Less(t, calls, len(replies), "unexpected OpenAI request")
+		content, _ := json.Marshal(replies[calls])
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "chatcmpl-1", "object": "chat.completion", "created": 0, "model": "gpt-4o",
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": %s}}]}`, content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// useFakeStripe points stripe-go at a fake API for the duration of the test
+func useFakeStripe(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	backend := stripeapi.GetBackendWithConfig(stripeapi.APIBackend, &stripeapi.BackendConfig{
+		URL:               stripeapi.String(server.URL),
+		MaxNetworkRetries: stripeapi.Int64(0),
+	})
+	stripeapi.SetBackend(stripeapi.APIBackend, backend)
+	t.Cleanup(func() { stripeapi.SetBackend(stripeapi.APIBackend, nil) })
+}
+
+func newTestHandler(t *testing.T, wildcardURL, openaiURL string) *MessageHandler {
+	keyStore := services.NewKeyStore()
+	require.NoError(t, keyStore.RegisterKey("user123", wildcard.APINameStripe, "sk_test_123"))
+	openaiService := services.NewOpenAIService("test", option.WithBaseURL(openaiURL+"/"))
+	registry := wildcard.NewRegistry()
+	registry.Register(wildcard.APINameStripe, stripe.NewExecutor(keyStore.Stripe()))
+	processor := services.NewProcessor(wildcardURL, registry, openaiService)
+	return NewMessageHandler(processor, keyStore)
+}
+
+// readStream decodes the events of an SSE response body, checking that each
+// is named after its update type and that IDs increase by one
+func readStream(t *testing.T, body string) []models.StreamUpdate {
+	var updates []models.StreamUpdate
+	for _, block := range strings.Split(body, "\n\n") {
+		fields := make(map[string]string)
+		for _, line := range strings.Split(block, "\n") {
+			if name, value, ok := strings.Cut(line, ": "); ok && name != "" {
+				fields[name] = value
+			}
+		}
+		if _, ok := fields["data"]; !ok {
+			continue
+		}
+		var update models.StreamUpdate
+		require.NoError(t, json.Unmarshal([]byte(fields["data"]), &update))
+		assert.Equal(t, update.Type, fields["event"])
+		assert.Equal(t, strconv.Itoa(len(updates)+1), fields["id"])
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+func TestStreamProcessEndToEnd(t *testing.T) {
+	var stripeRequests []string
+	useFakeStripe(t, func(w http.ResponseWriter, r *http.Request) {
+		stripeRequests = append(stripeRequests, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"object": "list", "data": [{"id": "cus_1", "object": "customer"}], "has_more": false}`))
+	})
+	wildcardServer := wildcardtest.NewServer(
+		wildcardtest.Exec("stripe_get_customers", map[string]interface{}{"limit": 1}),
+		wildcardtest.Stop(map[string]interface{}{"message": "Listed customers"}),
+	)
+	defer wildcardServer.Close()
+	openaiServer := newFakeOpenAI(t, "stripe This needs Stripe", "You have one customer.")
+
+	handler := newTestHandler(t, wildcardServer.URL, openaiServer.URL)
+	req := httptest.NewRequest(http.MethodPost, "/process-stream", strings.NewReader(`{"user_id": "user123", "message": "list my customers"}`))
+	rec := httptest.NewRecorder()
+	handler.StreamProcess(rec, req)
+
+	updates := readStream(t, rec.Body.String())
+	require.NotEmpty(t, updates)
+	assert.Equal(t, models.EventStart, updates[0].Type)
+	final := updates[len(updates)-1]
+	assert.Equal(t, models.EventComplete, final.Type)
+	assert.Equal(t, "You have one customer.", final.Data["message"])
+	assert.Equal(t, []string{"GET /v1/customers"}, stripeRequests)
+
+	messages := wildcardServer.Backend.Messages()
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[1].Message, "cus_1")
+}
+
+func TestStreamProcessTerminalEvents(t *testing.T) {
+	// Wildcard reports an error
+	wildcardServer := wildcardtest.NewServer(wildcardtest.Error("no matching function"))
+	defer wildcardServer.Close()
+	handler := newTestHandler(t, wildcardServer.URL, newFakeOpenAI(t, "stripe This needs Stripe").URL)
+	rec := httptest.NewRecorder()
+	handler.StreamProcess(rec, httptest.NewRequest(http.MethodPost, "/process-stream", strings.NewReader(`{"user_id": "user123", "message": "do something"}`)))
+
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "retry: 3000\n\n"), rec.Body.String())
+	updates := readStream(t, rec.Body.String())
+	require.NotEmpty(t, updates)
+	final := updates[len(updates)-1]
+	assert.Equal(t, models.EventError, final.Type)
+	assert.Contains(t, final.Data["error"], "no matching function")
+
+	// The request cannot be decoded
+	rec = httptest.NewRecorder()
+	handler.StreamProcess(rec, httptest.NewRequest(http.MethodPost, "/process-stream", strings.NewReader(`{`)))
+	updates = readStream(t, rec.Body.String())
+	require.Len(t, updates, 1)
+	assert.Equal(t, models.EventError, updates[0].Type)
+
+	// A reconnect is refused without running the message again
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/process-stream", strings.NewReader(`{"user_id": "user123", "message": "do something"}`))
+	req.Header.Set("Last-Event-ID", "3")
+	handler.StreamProcess(rec, req)
+	updates = readStream(t, rec.Body.String())
+	require.Len(t, updates, 1)
+	assert.Equal(t, models.EventError, updates[0].Type)
+	assert.Contains(t, updates[0].Data["error"], "resuming after event 3 is not supported")
+	assert.Len(t, wildcardServer.Backend.Messages(), 1, "the message is not sent again")
+}
+
+func TestStreamProcessStepFailed(t *testing.T) {
+	useFakeStripe(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"type": "invalid_request_error", "code": "resource_missing", "message": "No such customer: 'cus_missing'"}}`))
+	})
+	wildcardServer := wildcardtest.NewServer(
+		wildcardtest.Exec("stripe_get_customers_customer", map[string]interface{}{"customer": "cus_missing"}),
+		wildcardtest.Stop(map[string]interface{}{"message": "The customer does not exist"}),
+	)
+	defer wildcardServer.Close()
+	handler := newTestHandler(t, wildcardServer.URL, newFakeOpenAI(t, "stripe This needs Stripe", "That customer does not exist.").URL)
+	rec := httptest.NewRecorder()
+	handler.StreamProcess(rec, httptest.NewRequest(http.MethodPost, "/process-stream", strings.NewReader(`{"user_id": "user123", "message": "show customer cus_missing"}`)))
+
+	// A failed call is not terminal: Wildcard gets the failure and the run goes on
+	updates := readStream(t, rec.Body.String())
+	var failed []models.StreamUpdate
+	for _, update := range updates {
+		assert.NotEqual(t, models.EventError, update.Type)
+		if update.Type == models.EventStepFailed {
+			failed = append(failed, update)
+		}
+	}
+	require.Len(t, failed, 1)
+	assert.Contains(t, failed[0].Data["error"], "No such customer")
+	assert.Equal(t, models.EventComplete, updates[len(updates)-1].Type)
+	assert.Equal(t, "That customer does not exist.", updates[len(updates)-1].Data["message"])
+}
+
+func TestStreamProcessHeartbeat(t *testing.T) {
+	interval := SSEHeartbeatInterval
+	SSEHeartbeatInterval = 10 * time.Millisecond
+	t.Cleanup(func() { SSEHeartbeatInterval = interval })
+
+	openaiServer := newFakeOpenAI(t, "Hello!")
+	slowOpenAI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		openaiServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer slowOpenAI.Close()
+
+	handler := newTestHandler(t, "http://127.0.0.1:0", slowOpenAI.URL)
+	rec := httptest.NewRecorder()
+	handler.StreamProcess(rec, httptest.NewRequest(http.MethodPost, "/process-stream", strings.NewReader(`{"user_id": "user123", "message": "hi"}`)))
+
+	assert.Contains(t, rec.Body.String(), "\n\n: heartbeat\n\n")
+	updates := readStream(t, rec.Body.String())
+	require.NotEmpty(t, updates)
+	assert.Equal(t, models.EventComplete, updates[len(updates)-1].Type)
+	assert.Equal(t, "Hello!", updates[len(updates)-1].Data["message"])
+}
+
+func TestProcessMessageWildcardError(t *testing.T) {
+	wildcardServer := wildcardtest.NewServer(wildcardtest.Error("no matching function"))
+	defer wildcardServer.Close()
+	openaiServer := newFakeOpenAI(t, "stripe This needs Stripe")
+
+	handler := newTestHandler(t, wildcardServer.URL, openaiServer.URL)
+	req := httptest.NewRequest(http.MethodPost, "/process", strings.NewReader(`{"user_id": "user123", "message": "do something"}`))
+	rec := httptest.NewRecorder()
+	handler.ProcessMessage(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, false, resp["success"])
+	assert.Contains(t, resp["error"], "no matching function")
+}
+
+func TestHandleKeyRegistration(t *testing.T) {
+	handler := newTestHandler(t, "http://localhost:8000", "http://localhost:1")
+
+	rec := httptest.NewRecorder()
+	handler.HandleKeyRegistration(rec, httptest.NewRequest(http.MethodPost, "/register-key", strings.NewReader(`{"userId": "user456", "api": "stripe", "apiKey": "sk_test_456"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	key, err := handler.keyStore.GetKey("user456", "stripe")
+	require.NoError(t, err)
+	assert.Equal(t, "sk_test_456", key)
+
+	rec = httptest.NewRecorder()
+	handler.HandleKeyRegistration(rec, httptest.NewRequest(http.MethodPost, "/register-key", strings.NewReader(`{"userId": "user456", "api": "stripe", "apiKey": "sk_test_789", "name": "acme", "account": "acct_123"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "default", handler.keyStore.Default("user456", "stripe"), "named credentials are not selected unless asked")
+
+	rec = httptest.NewRecorder()
+	handler.HandleKeyRegistration(rec, httptest.NewRequest(http.MethodPost, "/register-key", strings.NewReader(`{"userId": "user456", "api": "stripe", "name": "acme", "default": true}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	credential, err := handler.keyStore.Credential("user456", "stripe", "")
+	require.NoError(t, err)
+	assert.Equal(t, wildcard.Credential{Name: "acme", Secret: "sk_test_789", Account: "acct_123"}, *credential)
+
+	rec = httptest.NewRecorder()
+	handler.HandleKeyRegistration(rec, httptest.NewRequest(http.MethodPost, "/register-key", strings.NewReader(`{"userId": "user456", "api": "stripe", "name": "missing", "default": true}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.HandleKeyRegistration(rec, httptest.NewRequest(http.MethodPost, "/register-key", strings.NewReader(`{"userId": "user456", "api": "unknown", "apiKey": "key"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
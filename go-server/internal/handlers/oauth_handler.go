@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wildcard-lovable/go-server/internal/services"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// OAuthStateTTL is how long a user has to complete the OAuth flow
+const OAuthStateTTL = 10 * time.Minute
+
+// OAuthStateCookie holds the state of the flow started in the caller's
+// browser, so that a callback with a state started elsewhere is refused
+const OAuthStateCookie = "stripe_oauth_state"
+
+// OAuthHandler handles the Stripe Connect OAuth flow, which stores the
+// connected account of a user as a named Stripe credential
+type OAuthHandler struct {
+	connect  *services.StripeConnect
+	keyStore *services.KeyStore
+	states   map[string]oauthState // state -> pending flow
+	mu       sync.Mutex
+	now      func() time.Time
+}
+
+// oauthState is a pending OAuth flow
+type oauthState struct {
+	userID  string
+	expires time.Time
+}
+
+// NewOAuthHandler creates a new OAuth handler
+func NewOAuthHandler(connect *services.StripeConnect, keyStore *services.KeyStore) *OAuthHandler {
+	return &OAuthHandler{
+		connect:  connect,
+		keyStore: keyStore,
+		states:   make(map[string]oauthState),
+		now:      time.Now,
+	}
+}
+
+// StartStripe handles GET /oauth/stripe/start?userId=... by redirecting the
+// user to Stripe. Clients that accept JSON get the authorize URL instead. The
+// state is also set as a cookie, which the callback must carry.
+func (h *OAuthHandler) StartStripe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		http.Error(w, "userId is required", http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.newState(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	authorizeURL := h.connect.AuthorizeURL(state)
+	http.SetCookie(w, &http.Cookie{
+		Name:     OAuthStateCookie,
+		Value:    state,
+		Path:     "/oauth/stripe",
+		MaxAge:   int(OAuthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		// Lax, so that the cookie is sent on the redirect back from Stripe
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"url": authorizeURL})
+		return
+	}
+	http.Redirect(w, r, authorizeURL, http.StatusFound)
+}
+
+// StripeCallback handles GET /oauth/stripe/callback, where Stripe sends the
+// user back with an authorization code. The state must match the cookie set
+// by StartStripe. The connected account becomes the user's default Stripe
+// credential.
+func (h *OAuthHandler) StripeCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	state := query.Get("state")
+
+	// The state is only accepted from the browser that started the flow
+	cookie, err := r.Cookie(OAuthStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "the state does not belong to this session", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: OAuthStateCookie, Path: "/oauth/stripe", MaxAge: -1})
+
+	userID, ok := h.takeState(state)
+	if !ok {
+		http.Error(w, "invalid or expired state", http.StatusBadRequest)
+		return
+	}
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, fmt.Sprintf("authorization failed: %s: %s", errCode, query.Get("error_description")), http.StatusBadRequest)
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := h.connect.Exchange(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := h.keyStore.RegisterCredential(userID, wildcard.APINameStripe, credential); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.keyStore.SetDefault(userID, wildcard.APINameStripe, credential.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "account": credential.Name})
+}
+
+type OAuthRevokeRequest struct {
+	UserID  string `json:"userId"`
+	Account string `json:"account"`
+}
+
+// RevokeStripe handles POST /oauth/stripe/revoke by disconnecting a connected
+// account of the user and removing its credential. Credentials not bound to a
+// connected account, such as pasted keys, cannot be revoked this way.
+func (h *OAuthHandler) RevokeStripe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req OAuthRevokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" || req.Account == "" {
+		http.Error(w, "userId and account are required", http.StatusBadRequest)
+		return
+	}
+
+	// Only accounts the user connected can be revoked
+	credential, err := h.keyStore.Credential(req.UserID, wildcard.APINameStripe, req.Account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if credential.Account == "" {
+		http.Error(w, fmt.Sprintf("%s is not a connected account", req.Account), http.StatusBadRequest)
+		return
+	}
+	if err := h.connect.Revoke(credential.Account); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := h.keyStore.RemoveCredential(req.UserID, wildcard.APINameStripe, req.Account); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// newState starts a flow for a user and returns its unguessable state
+func (h *OAuthHandler) newState(userID string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate state: %v", err)
+	}
+	state := hex.EncodeToString(b)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	for s, pending := range h.states {
+		if now.After(pending.expires) {
+			delete(h.states, s)
+		}
+	}
+	h.states[state] = oauthState{userID: userID, expires: now.Add(OAuthStateTTL)}
+	return state, nil
+}
+
+// takeState ends a flow and returns its user. A state can be used only once.
+func (h *OAuthHandler) takeState(state string) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pending, exists := h.states[state]
+	if !exists {
+		return "", false
+	}
+	delete(h.states, state)
+	if h.now().After(pending.expires) {
+		return "", false
+	}
+	return pending.userID, true
+}
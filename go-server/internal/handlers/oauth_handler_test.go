@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wildcard-lovable/go-server/internal/services"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// newFakeConnect stands in for the Stripe Connect token endpoints, accepting
+// the code "ac_123" and the refresh token "rt_123", answering "ac_no_token" without an access token and
+// recording the requests it receives
+func newFakeConnect(t *testing.T) (*httptest.Server, *[]url.Values) {
+	var requests []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "Bearer sk_platform", r.Header.Get("Authorization"))
+		requests = append(requests, r.PostForm)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/oauth/token" && r.PostForm.Get("code") == "ac_123":
+			fmt.Fprint(w, `{"access_token": "sk_connected", "refresh_token": "rt_123", "stripe_user_id": "acct_123", "livemode": false, "scope": "read_write", "token_type": "bearer"}`)
+		case r.URL.Path == "/oauth/token" && r.PostForm.Get("refresh_token") == "rt_123":
+			fmt.Fprint(w, `{"access_token": "sk_rolled", "refresh_token": "rt_456", "stripe_user_id": "acct_123", "livemode": false, "scope": "read_write", "token_type": "bearer"}`)
+		case r.URL.Path == "/oauth/token" && r.PostForm.Get("code") == "ac_no_token":
+			fmt.Fprint(w, `{"stripe_user_id": "acct_123", "livemode": false, "scope": "read_write"}`)
+		case r.URL.Path == "/oauth/deauthorize":
+			fmt.Fprintf(w, `{"stripe_user_id": %q}`, r.PostForm.Get("stripe_user_id"))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "invalid_grant", "error_description": "Authorization code does not exist"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// startOAuth starts a flow for the user and returns the state Stripe would
+// send back, along with the state cookie of the user's browser
+func startOAuth(t *testing.T, handler *OAuthHandler, userID string) (string, *http.Cookie) {
+	rec := httptest.NewRecorder()
+	handler.StartStripe(rec, httptest.NewRequest(http.MethodGet, "/oauth/stripe/start?userId="+userID, nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	return location.Query().Get("state"), stateCookie(t, rec)
+}
+
+// stateCookie returns the state cookie set by a response
+func stateCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == OAuthStateCookie {
+			return cookie
+		}
+	}
+	require.Fail(t, "no state cookie was set")
+	return nil
+}
+
+// callback sends the browser holding cookie back to the callback with query
+func callback(handler *OAuthHandler, query string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/oauth/stripe/callback?"+query, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler.StripeCallback(rec, req)
+	return rec
+}
+
+func TestStripeOAuthFlow(t *testing.T) {
+	server, requests := newFakeConnect(t)
+	keyStore := services.NewKeyStore()
+	require.NoError(t, keyStore.RegisterKey("user123", wildcard.APINameStripe, "sk_test_123"))
+	connect := services.NewStripeConnect("ca_123", "sk_platform", "https://app.example.com/oauth/stripe/callback", server.URL)
+	handler := NewOAuthHandler(connect, keyStore)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/oauth/stripe/start?userId=user123", nil)
+	req.Header.Set("Accept", "application/json")
+	handler.StartStripe(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var start map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &start))
+	assert.True(t, strings.HasPrefix(start["url"], server.URL+"/oauth/authorize?"), start["url"])
+	authorize, err := url.Parse(start["url"])
+	require.NoError(t, err)
+	assert.Equal(t, "ca_123", authorize.Query().Get("client_id"))
+	assert.Equal(t, "https://app.example.com/oauth/stripe/callback", authorize.Query().Get("redirect_uri"))
+	state := authorize.Query().Get("state")
+	require.Len(t, state, 32)
+	cookie := stateCookie(t, rec)
+	assert.Equal(t, state, cookie.Value)
+	assert.True(t, cookie.HttpOnly)
+
+	rec = callback(handler, "code=ac_123&state="+state, cookie)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"status": "success", "account": "acct_123"}`, rec.Body.String())
+	assert.Equal(t, "authorization_code", (*requests)[0].Get("grant_type"))
+
+	credential, err := keyStore.Credential("user123", wildcard.APINameStripe, "")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(services.ConnectTokenLifetime), credential.ExpiresAt, time.Minute)
+	credential.ExpiresAt = time.Time{}
+	assert.Equal(t, wildcard.Credential{Name: "acct_123", Secret: "sk_connected", RefreshToken: "rt_123", Account: "acct_123"}, *credential)
+	assert.Len(t, keyStore.Credentials("user123", wildcard.APINameStripe), 2, "the pasted key is kept")
+
+	// A state cannot be replayed
+	rec = callback(handler, "code=ac_123&state="+state, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Only the user's own accounts can be revoked
+	rec = httptest.NewRecorder()
+	handler.RevokeStripe(rec, httptest.NewRequest(http.MethodPost, "/oauth/stripe/revoke", strings.NewReader(`{"userId": "other", "account": "acct_123"}`)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// A pasted key is not a connected account
+	rec = httptest.NewRecorder()
+	handler.RevokeStripe(rec, httptest.NewRequest(http.MethodPost, "/oauth/stripe/revoke", strings.NewReader(`{"userId": "user123", "account": "default"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Len(t, *requests, 1, "nothing is sent to Stripe")
+
+	rec = httptest.NewRecorder()
+	handler.RevokeStripe(rec, httptest.NewRequest(http.MethodPost, "/oauth/stripe/revoke", strings.NewReader(`{"userId": "user123", "account": "acct_123"}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, *requests, 2)
+	assert.Equal(t, url.Values{"client_id": {"ca_123"}, "stripe_user_id": {"acct_123"}}, (*requests)[1])
+	_, err = keyStore.Credential("user123", wildcard.APINameStripe, "acct_123")
+	assert.Error(t, err)
+}
+
+func TestStripeOAuthCallbackErrors(t *testing.T) {
+	server, _ := newFakeConnect(t)
+	keyStore := services.NewKeyStore()
+	handler := NewOAuthHandler(services.NewStripeConnect("ca_123", "sk_platform", "", server.URL), keyStore)
+
+	rec := httptest.NewRecorder()
+	handler.StartStripe(rec, httptest.NewRequest(http.MethodGet, "/oauth/stripe/start", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// The user declined access
+	state, cookie := startOAuth(t, handler, "user123")
+	rec = callback(handler, "error=access_denied&state="+state, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "access_denied")
+
+	// The code is rejected by the token endpoint
+	state, cookie = startOAuth(t, handler, "user123")
+	rec = callback(handler, "code=ac_bad&state="+state, cookie)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Contains(t, rec.Body.String(), "failed to exchange the authorization code")
+
+	// The platform's secret key is never stored for the user
+	state, cookie = startOAuth(t, handler, "user123")
+	rec = callback(handler, "code=ac_no_token&state="+state, cookie)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Contains(t, rec.Body.String(), "the token response has no access token for acct_123")
+
+	// The callback comes from another browser than the one that started the
+	// flow, e.g. a victim lured to an attacker's callback link
+	state, _ = startOAuth(t, handler, "user123")
+	_, otherCookie := startOAuth(t, handler, "user123")
+	rec = callback(handler, "code=ac_123&state="+state, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = callback(handler, "code=ac_123&state="+state, otherCookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// The state has expired
+	state, cookie = startOAuth(t, handler, "user123")
+	handler.now = func() time.Time { return time.Now().Add(OAuthStateTTL + time.Second) }
+	rec = callback(handler, "code=ac_123&state="+state, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	assert.Empty(t, keyStore.APIs("user123"))
+}
+
+func TestStripeConnectRefresh(t *testing.T) {
+	server, requests := newFakeConnect(t)
+	connect := services.NewStripeConnect("ca_123", "sk_platform", "", server.URL)
+
+	refreshed, err := connect.Refresh(wildcard.Credential{Name: "acct_123", Secret: "sk_connected", RefreshToken: "rt_123", Account: "acct_123"})
+	require.NoError(t, err)
+	assert.Equal(t, "sk_rolled", refreshed.Secret)
+	assert.Equal(t, "rt_456", refreshed.RefreshToken)
+	assert.WithinDuration(t, time.Now().Add(services.ConnectTokenLifetime), refreshed.ExpiresAt, time.Minute)
+	require.Len(t, *requests, 1)
+	assert.Equal(t, url.Values{"client_secret": {"sk_platform"}, "grant_type": {"refresh_token"}, "refresh_token": {"rt_123"}}, (*requests)[0])
+
+	_, err = connect.Refresh(wildcard.Credential{Name: "acct_123", Secret: "sk_connected", RefreshToken: "rt_revoked", Account: "acct_123"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to refresh the access token of acct_123")
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/wildcard-lovable/go-server/internal/models"
+)
+
+// SSEHeartbeatInterval is how often a comment is sent while no update is, so
+// that proxies do not time out idle streams during long calls
+var SSEHeartbeatInterval = 15 * time.Second
+
+// SSERetry is the reconnection delay suggested to clients when a stream opens
+const SSERetry = 3 * time.Second
+
+// sseStream writes server-sent events. Each update is sent as an event named
+// after its type, with an ID that increases by one per event.
+type sseStream struct {
+	w       io.Writer
+	flusher http.Flusher
+	lastID  int
+	last    string // type of the last update sent
+}
+
+// newSSEStream sets the SSE headers and sends the retry hint, so that clients
+// see the stream open before the first event
+func newSSEStream(w http.ResponseWriter, flusher http.Flusher) *sseStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	fmt.Fprintf(w, "retry: %d\n\n", SSERetry.Milliseconds())
+	flusher.Flush()
+	return &sseStream{w: w, flusher: flusher}
+}
+
+// send writes an update as an event
+func (s *sseStream) send(update models.StreamUpdate) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	s.lastID++
+	s.last = update.Type
+	if _, err := fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", s.lastID, update.Type, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// sendError writes an error event
+func (s *sseStream) sendError(msg string, err error) {
+	s.send(models.StreamUpdate{
+		Type: models.EventError,
+		Data: map[string]interface{}{
+			"message": msg,
+			"error":   err.Error(),
+		},
+	})
+}
+
+// heartbeat writes a comment, which clients ignore
+func (s *sseStream) heartbeat() {
+	fmt.Fprint(s.w, ": heartbeat\n\n")
+	s.flusher.Flush()
+}
+
+// terminated reports whether the last event ended the stream. A step_failed
+// event does not, since processing continues after it.
+func (s *sseStream) terminated() bool {
+	return s.last == models.EventComplete || s.last == models.EventError
+}
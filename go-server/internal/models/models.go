@@ -15,14 +15,15 @@ type APIResponse struct {
 
 // StreamUpdate represents a single update in the SSE stream
 type StreamUpdate struct {
-	Type string                 `json:"type"` // "start", "progress", "complete", "error"
+	Type string                 `json:"type"` // "start", "progress", "step_failed", "complete", "error"
 	Data map[string]interface{} `json:"data"`
 }
 
 // Event types for stream updates
 const (
-	EventStart    = "start"    // Initial event when processing starts
-	EventProgress = "progress" // Progress updates during processing
-	EventComplete = "complete" // Final success event
-	EventError    = "error"    // Error event
+	EventStart      = "start"       // Initial event when processing starts
+	EventProgress   = "progress"    // Progress updates during processing
+	EventStepFailed = "step_failed" // A function call failed; processing continues
+	EventComplete   = "complete"    // Final success event
+	EventError      = "error"       // Error event
 )
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// DefaultCredentialName names the credential registered by RegisterKey
+const DefaultCredentialName = "default"
+
+// RefreshFunc exchanges the refresh token of an expired credential for a new
+// secret. It returns the refreshed credential.
+type RefreshFunc func(credential wildcard.Credential) (wildcard.Credential, error)
+
+// KeyStore manages the credentials of users per integration. A user can hold
+// several named credentials for an integration, e.g. test and live Stripe keys,
+// one of which is their default.
+type KeyStore struct {
+	credentials map[string]map[string]*userCredentials // userID -> API name -> credentials
+	refreshers  map[string]RefreshFunc                 // API name -> refresher
+	mu          sync.RWMutex
+	refreshMu   sync.Mutex
+	now         func() time.Time
+}
+
+// userCredentials are the credentials of a user for one integration
+type userCredentials struct {
+	byName      map[string]wildcard.Credential
+	defaultName string
+}
+
+// NewKeyStore creates a new KeyStore
+func NewKeyStore() *KeyStore {
+	return &KeyStore{
+		credentials: make(map[string]map[string]*userCredentials),
+		refreshers:  make(map[string]RefreshFunc),
+		now:         time.Now,
+	}
+}
+
+// RegisterKey registers a user's API key for an integration as their default
+// credential, replacing a previously registered key
+func (s *KeyStore) RegisterKey(userID, api, apiKey string) error {
+	if err := s.RegisterCredential(userID, api, wildcard.Credential{Name: DefaultCredentialName, Secret: apiKey}); err != nil {
+		return err
+	}
+	return s.SetDefault(userID, api, DefaultCredentialName)
+}
+
+// RegisterCredential adds or replaces a named credential of a user for an
+// integration. The user's first credential for the integration becomes their default.
+func (s *KeyStore) RegisterCredential(userID, api string, credential wildcard.Credential) error {
+	if userID == "" || api == "" || credential.Secret == "" {
+		return fmt.Errorf("userID, api and apiKey cannot be empty")
+	}
+	if credential.Name == "" {
+		return fmt.Errorf("credential name cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.credentials[userID] == nil {
+		s.credentials[userID] = make(map[string]*userCredentials)
+	}
+	creds := s.credentials[userID][api]
+	if creds == nil {
+		creds = &userCredentials{byName: make(map[string]wildcard.Credential)}
+		s.credentials[userID][api] = creds
+	}
+	creds.byName[credential.Name] = credential
+	if creds.defaultName == "" {
+		creds.defaultName = credential.Name
+	}
+	return nil
+}
+
+// SetDefault selects the credential used for an integration when a function
+// call does not name one
+func (s *KeyStore) SetDefault(userID, api, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creds := s.credentials[userID][api]
+	if creds == nil {
+		return fmt.Errorf("no %s API key found for user %s", api, userID)
+	}
+	if _, exists := creds.byName[name]; !exists {
+		return fmt.Errorf("no %s credential named %q found for user %s", api, name, userID)
+	}
+	creds.defaultName = name
+	return nil
+}
+
+// Default returns the name of a user's default credential for an integration
+func (s *KeyStore) Default(userID, api string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if creds := s.credentials[userID][api]; creds != nil {
+		return creds.defaultName
+	}
+	return ""
+}
+
+// Credential retrieves a user's credential for an integration by name, or
+// their default credential if name is empty. An expired credential is
+// refreshed with the integration's refresher.
+func (s *KeyStore) Credential(userID, api, name string) (*wildcard.Credential, error) {
+	credential, err := s.lookup(userID, api, name)
+	if err != nil {
+		return nil, err
+	}
+	if !credential.Expired(s.now()) {
+		return &credential, nil
+	}
+	return s.refresh(userID, api, credential.Name)
+}
+
+// lookup returns a copy of a stored credential
+func (s *KeyStore) lookup(userID, api, name string) (wildcard.Credential, error) {
+	if userID == "" {
+		return wildcard.Credential{}, fmt.Errorf("userID cannot be empty")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	creds := s.credentials[userID][api]
+	if creds == nil {
+		return wildcard.Credential{}, fmt.Errorf("no %s API key found for user %s", api, userID)
+	}
+	if name == "" {
+		if creds.defaultName == "" {
+			return wildcard.Credential{}, fmt.Errorf("no default %s credential selected for user %s", api, userID)
+		}
+		name = creds.defaultName
+	}
+	credential, exists := creds.byName[name]
+	if !exists {
+		return wildcard.Credential{}, fmt.Errorf("no %s credential named %q found for user %s", api, name, userID)
+	}
+	return credential, nil
+}
+
+// refresh refreshes an expired credential and stores the result. Refreshes
+// are serialized so that concurrent calls do not spend the refresh token twice.
+func (s *KeyStore) refresh(userID, api, name string) (*wildcard.Credential, error) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	// Another call may have refreshed the credential while this one waited
+	credential, err := s.lookup(userID, api, name)
+	if err != nil {
+		return nil, err
+	}
+	if !credential.Expired(s.now()) {
+		return &credential, nil
+	}
+
+	s.mu.RLock()
+	refresher := s.refreshers[api]
+	s.mu.RUnlock()
+	if refresher == nil || !credential.Refreshable() {
+		return nil, fmt.Errorf("%s credential %q of user %s has expired", api, name, userID)
+	}
+
+	refreshed, err := refresher(credential)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh %s credential %q of user %s: %v", api, name, userID, err)
+	}
+	refreshed.Name = name
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = credential.RefreshToken
+	}
+	// A credential stays bound to its connected account
+	refreshed.Account = credential.Account
+	if err := s.RegisterCredential(userID, api, refreshed); err != nil {
+		return nil, err
+	}
+	return &refreshed, nil
+}
+
+// SetRefresher sets how the expired credentials of an integration are refreshed
+func (s *KeyStore) SetRefresher(api string, refresher RefreshFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshers[api] = refresher
+}
+
+// Credentials returns a user's credentials for an integration, sorted by name
+func (s *KeyStore) Credentials(userID, api string) []wildcard.Credential {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	creds := s.credentials[userID][api]
+	if creds == nil {
+		return nil
+	}
+	list := make([]wildcard.Credential, 0, len(creds.byName))
+	for _, credential := range creds.byName {
+		list = append(list, credential)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// GetKey retrieves a user's default API key for an integration
+func (s *KeyStore) GetKey(userID, api string) (string, error) {
+	credential, err := s.Credential(userID, api, "")
+	if err != nil {
+		return "", err
+	}
+	return credential.Secret, nil
+}
+
+// RemoveCredential removes a named credential of a user. If it was the
+// default, the user has no default until they select one.
+func (s *KeyStore) RemoveCredential(userID, api, name string) error {
+	if userID == "" {
+		return fmt.Errorf("userID cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creds := s.credentials[userID][api]
+	if creds == nil {
+		return nil
+	}
+	delete(creds.byName, name)
+	if creds.defaultName == name {
+		creds.defaultName = ""
+	}
+	if len(creds.byName) == 0 {
+		s.removeLocked(userID, api)
+	}
+	return nil
+}
+
+// RemoveKey removes all of a user's credentials for an integration
+func (s *KeyStore) RemoveKey(userID, api string) error {
+	if userID == "" {
+		return fmt.Errorf("userID cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(userID, api)
+	return nil
+}
+
+// removeLocked removes a user's credentials for an integration. s.mu must be held.
+func (s *KeyStore) removeLocked(userID, api string) {
+	delete(s.credentials[userID], api)
+	if len(s.credentials[userID]) == 0 {
+		delete(s.credentials, userID)
+	}
+}
+
+// APIs returns the integrations a user has registered keys for, sorted
+func (s *KeyStore) APIs(userID string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apis := make([]string, 0, len(s.credentials[userID]))
+	for api := range s.credentials[userID] {
+		apis = append(apis, api)
+	}
+	sort.Strings(apis)
+	return apis
+}
+
+// Configured reports whether a user has registered a key for an integration
+func (s *KeyStore) Configured(userID, api string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.credentials[userID][api] != nil
+}
+
+// Stripe returns a view of the Stripe keys in the store
+func (s *KeyStore) Stripe() *StripeKeyStore {
+	return &StripeKeyStore{keys: s}
+}
+
+// For returns a view of the keys of one integration in the store
+func (s *KeyStore) For(api string) *IntegrationKeys {
+	return &IntegrationKeys{keys: s, api: api}
+}
+
+// IntegrationKeys is a view of the keys of one integration in a KeyStore
+type IntegrationKeys struct {
+	keys *KeyStore
+	api  string
+}
+
+// GetToken retrieves a user's credential for the integration by name, or
+// their default credential if name is empty
+func (k *IntegrationKeys) GetToken(userID, name string) (string, error) {
+	credential, err := k.keys.Credential(userID, k.api, name)
+	if err != nil {
+		return "", err
+	}
+	return credential.Secret, nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+func TestKeyStorePerIntegration(t *testing.T) {
+	keys := NewKeyStore()
+	assert.NoError(t, keys.RegisterKey("user123", "stripe", "sk_test_123"))
+	assert.NoError(t, keys.RegisterKey("user123", "github", "ghp_123"))
+	assert.Error(t, keys.RegisterKey("user123", "", "key"))
+
+	stripeKey, err := keys.Stripe().GetStripeKey("user123")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk_test_123", stripeKey)
+	githubKey, err := keys.GetKey("user123", "github")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghp_123", githubKey)
+	githubKey, err = keys.For("github").GetToken("user123", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghp_123", githubKey)
+	assert.Equal(t, []string{"github", "stripe"}, keys.APIs("user123"))
+	assert.True(t, keys.Configured("user123", "github"))
+	assert.False(t, keys.Configured("user123", "slack"))
+	assert.False(t, keys.Configured("other", "github"))
+
+	assert.NoError(t, keys.Stripe().RemoveKey("user123"))
+	_, err = keys.GetKey("user123", "stripe")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"github"}, keys.APIs("user123"))
+}
+
+func TestKeyStoreCredentials(t *testing.T) {
+	keys := NewKeyStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return now }
+
+	assert.NoError(t, keys.RegisterCredential("user123", "stripe", wildcard.Credential{Name: "test", Secret: "sk_test_123"}))
+	assert.NoError(t, keys.RegisterCredential("user123", "stripe", wildcard.Credential{Name: "live", Secret: "sk_live_123"}))
+	assert.NoError(t, keys.RegisterCredential("user123", "stripe", wildcard.Credential{Name: "acme", Secret: "sk_live_123", Account: "acct_123"}))
+	assert.Error(t, keys.RegisterCredential("user123", "stripe", wildcard.Credential{Secret: "sk_test_123"}), "credentials are named")
+	assert.Equal(t, "test", keys.Default("user123", "stripe"), "the first credential is the default")
+
+	key, err := keys.GetKey("user123", "stripe")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk_test_123", key)
+	credential, err := keys.Stripe().StripeCredential("user123", "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "acct_123", credential.Account)
+	_, err = keys.Credential("user123", "stripe", "missing")
+	assert.Error(t, err)
+
+	assert.NoError(t, keys.SetDefault("user123", "stripe", "live"))
+	assert.Error(t, keys.SetDefault("user123", "stripe", "missing"))
+	key, err = keys.Stripe().GetStripeKey("user123")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk_live_123", key)
+
+	var names []string
+	for _, c := range keys.Credentials("user123", "stripe") {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"acme", "live", "test"}, names)
+
+	// Removing the default leaves no default rather than switching to another key
+	assert.NoError(t, keys.RemoveCredential("user123", "stripe", "live"))
+	_, err = keys.GetKey("user123", "stripe")
+	assert.Error(t, err)
+	assert.NoError(t, keys.SetDefault("user123", "stripe", "test"))
+
+	// RegisterKey replaces the default credential
+	assert.NoError(t, keys.RegisterKey("user123", "stripe", "sk_test_456"))
+	key, err = keys.GetKey("user123", "stripe")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk_test_456", key)
+
+	assert.NoError(t, keys.RemoveKey("user123", "stripe"))
+	assert.Empty(t, keys.APIs("user123"))
+}
+
+func TestKeyStoreRefresh(t *testing.T) {
+	keys := NewKeyStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return now }
+
+	assert.NoError(t, keys.RegisterCredential("user123", "github", wildcard.Credential{
+		Name:         "oauth",
+		Secret:       "token_1",
+		RefreshToken: "refresh_1",
+		Account:      "acct_123",
+		ExpiresAt:    now.Add(time.Hour),
+	}))
+
+	refreshes := 0
+	keys.SetRefresher("github", func(c wildcard.Credential) (wildcard.Credential, error) {
+		refreshes++
+		if c.RefreshToken != "refresh_1" {
+			return wildcard.Credential{}, fmt.Errorf("invalid refresh token")
+		}
+		return wildcard.Credential{Secret: fmt.Sprintf("token_%d", refreshes+1), ExpiresAt: now.Add(time.Hour)}, nil
+	})
+
+	token, err := keys.For("github").GetToken("user123", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "token_1", token, "valid tokens are not refreshed")
+
+	// Tokens are refreshed shortly before they expire
+	now = now.Add(time.Hour - wildcard.ExpiryLeeway)
+	token, err = keys.For("github").GetToken("user123", "oauth")
+	assert.NoError(t, err)
+	assert.Equal(t, "token_2", token)
+	credential, err := keys.Credential("user123", "github", "oauth")
+	assert.NoError(t, err)
+	assert.Equal(t, "refresh_1", credential.RefreshToken, "the refresh token is kept")
+	assert.Equal(t, "acct_123", credential.Account, "the account binding is kept")
+	assert.Equal(t, 1, refreshes)
+
+	// Without a refresher an expired credential cannot be used
+	keys.SetRefresher("github", nil)
+	now = now.Add(2 * time.Hour)
+	_, err = keys.GetKey("user123", "github")
+	assert.Error(t, err)
+}
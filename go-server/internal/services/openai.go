@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -13,36 +14,67 @@ type OpenAIService struct {
 	client *openai.Client
 }
 
-// NewOpenAIService creates a new OpenAI service
-func NewOpenAIService(apiKey string) *OpenAIService {
-	client := openai.NewClient(option.WithAPIKey(apiKey))
+// NewOpenAIService creates a new OpenAI service. Options such as
+// option.WithBaseURL are applied after the API key.
+func NewOpenAIService(apiKey string, opts ...option.RequestOption) *OpenAIService {
+	client := openai.NewClient(append([]option.RequestOption{option.WithAPIKey(apiKey)}, opts...)...)
 	return &OpenAIService{
 		client: client,
 	}
 }
 
-// InterpretMessage sends a message to OpenAI to determine if it requires Stripe integration
-func (s *OpenAIService) InterpretMessage(ctx context.Context, message string) (bool, string, error) {
+// Integration describes an integration a message can be routed to
+type Integration struct {
+	API         string
+	Description string
+}
+
+// ClassifyMessage sends a message to OpenAI to determine which integration it
+// requires. It returns the API name of the integration with a brief explanation,
+// or an empty API name with a helpful response if it requires none.
+func (s *OpenAIService) ClassifyMessage(ctx context.Context, message string, integrations []Integration) (string, string, error) {
+	var available strings.Builder
+	for _, integration := range integrations {
+		fmt.Fprintf(&available, "- %s: %s\n", integration.API, integration.Description)
+	}
+
 	resp, err := s.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Model: openai.F(openai.ChatModelGPT4o),
 		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("You are a helpful assistant. If the user's message requires Stripe API integration (like payments, customers, products, etc.), respond with 'true' followed by a brief explanation. Otherwise, respond with 'false' and provide a helpful response to their query."),
+			openai.SystemMessage("You are a helpful assistant. The following integrations are available:\n" + available.String() + "If the user's message requires one of these integrations, respond with the integration's name followed by a brief explanation. Otherwise, respond with 'none' and provide a helpful response to their query."),
 			openai.UserMessage(message),
 		}),
 	})
 
 	if err != nil {
-		return false, "", fmt.Errorf("failed to interpret message: %w", err)
+		return "", "", fmt.Errorf("failed to classify message: %w", err)
 	}
 
 	if len(resp.Choices) > 0 {
-		content := resp.Choices[0].Message.Content
-		isStripeRelated := len(content) >= 4 && content[:4] == "true"
-		response := content[4:] // Get the explanation/response part
-		return isStripeRelated, response, nil
+		api, response := parseClassification(resp.Choices[0].Message.Content, integrations)
+		return api, response, nil
 	}
 
-	return false, "", nil
+	return "", "", nil
+}
+
+// parseClassification splits a classifier reply into the integration it names,
+// if any, and the rest of the reply
+func parseClassification(content string, integrations []Integration) (string, string) {
+	content = strings.TrimSpace(content)
+	word, rest, _ := strings.Cut(content, " ")
+	word = strings.ToLower(strings.Trim(word, ":.,'\"`*"))
+	rest = strings.TrimSpace(rest)
+
+	if word == "none" {
+		return "", rest
+	}
+	for _, integration := range integrations {
+		if word == strings.ToLower(integration.API) {
+			return integration.API, rest
+		}
+	}
+	return "", content
 }
 
 // GenerateSummary generates a user-friendly summary of the actions taken
@@ -50,7 +82,7 @@ func (s *OpenAIService) GenerateSummary(ctx context.Context, summaryContext stri
 	resp, err := s.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Model: openai.F(openai.ChatModelGPT4o),
 		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("You are a helpful assistant. Generate a clear, concise summary of the Stripe actions that were taken. Focus on what was accomplished and any relevant details a user would want to know. Be friendly and professional. Briefly describe each of the steps taken as bullet points near the beginning"),
+			openai.SystemMessage("You are a helpful assistant. Generate a clear, concise summary of the actions that were taken with the user's integrations. Focus on what was accomplished and any relevant details a user would want to know. Be friendly and professional. Briefly describe each of the steps taken as bullet points near the beginning"),
 			openai.UserMessage(summaryContext),
 		}),
 	})
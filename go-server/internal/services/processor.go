@@ -3,21 +3,32 @@ package services
 import (
 	"context"
 	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/wildcard-lovable/go-server/internal/cassette"
 	"github.com/wildcard-lovable/go-server/pkg/wildcard"
-	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/stripe"
 )
 
 // Processor handles the processing of user messages
 type Processor struct {
 	wildcardClient *wildcard.Client
 	openaiService  *OpenAIService
+	keyStore       *KeyStore
+
+	// Runs are serialized while recording or replaying so that each cassette
+	// holds the exchanges of a single run
+	recorder    *cassette.Recorder
+	cassetteDir string
+	runMu       sync.Mutex
 }
 
-// NewProcessor creates a new processor instance
-func NewProcessor(wildcardBaseURL string, stripeExecutor *stripe.Executor, openaiService *OpenAIService) *Processor {
-	client := wildcard.NewClient(wildcardBaseURL)
-	client.RegisterExecutor(wildcard.APINameStripe, stripeExecutor)
+// NewProcessor creates a new processor instance executing functions with the
+// executors of the registry
+func NewProcessor(wildcardBaseURL string, registry *wildcard.Registry, openaiService *OpenAIService) *Processor {
+	client := wildcard.NewClientWithRegistry(wildcardBaseURL, registry)
 
 	return &Processor{
 		wildcardClient: client,
@@ -25,23 +36,118 @@ func NewProcessor(wildcardBaseURL string, stripeExecutor *stripe.Executor, opena
 	}
 }
 
+// Client returns the processor's Wildcard client
+func (p *Processor) Client() *wildcard.Client {
+	return p.wildcardClient
+}
+
+// Registry returns the registry of the processor's executors
+func (p *Processor) Registry() *wildcard.Registry {
+	return p.wildcardClient.Registry()
+}
+
+// Integrations describes the registered integrations for the classifier
+func (p *Processor) Integrations() []Integration {
+	registry := p.Registry()
+	var integrations []Integration
+	for _, api := range registry.APIs() {
+		integration := Integration{API: api, Description: api}
+		if executor, ok := registry.Executor(api); ok {
+			if describer, ok := executor.(wildcard.Describer); ok {
+				integration.Description = describer.Description()
+			}
+		}
+		integrations = append(integrations, integration)
+	}
+	return integrations
+}
+
+// SetKeyStore sets the store of users' keys. Messages targeting an integration
+// the user has no key for are then answered without starting a run.
+func (p *Processor) SetKeyStore(keyStore *KeyStore) {
+	p.keyStore = keyStore
+}
+
+// notConfigured returns the error for a user without a key for an integration,
+// or nil if they have one, the integration needs none or no key store is set
+func (p *Processor) notConfigured(userID, api string) error {
+	if p.keyStore == nil || p.keyStore.Configured(userID, api) {
+		return nil
+	}
+	if executor, ok := p.Registry().Executor(api); ok {
+		if requirer, ok := executor.(wildcard.CredentialRequirer); ok && !requirer.RequiresCredentials() {
+			return nil
+		}
+	}
+	return fmt.Errorf("the %s integration is not configured; register an API key for it first", api)
+}
+
+// SetRedactor sets the redactor applied to function results before they are sent
+// to Wildcard and OpenAI. A nil redactor disables redaction.
+func (p *Processor) SetRedactor(redactor *wildcard.Redactor) {
+	p.wildcardClient.SetRedactor(redactor)
+}
+
+// SetRecorder records or replays the requests of each run to Wildcard through
+// the recorder. Recorded runs are saved to dir, one cassette per run. The OpenAI
+// service and the Stripe executor are routed through the recorder when they are
+// created, with option.WithHTTPClient and stripe.UseHTTPClient. Cassettes are
+// saved unredacted so that they replay exactly, and so contain the PII of the
+// run's messages and results.
+func (p *Processor) SetRecorder(recorder *cassette.Recorder, dir string) {
+	p.recorder = recorder
+	p.cassetteDir = dir
+	p.wildcardClient.SetHTTPClient(recorder.Client())
+}
+
+// beginRun starts the cassette of a run when a recorder is set. The returned
+// function ends the run, saving the cassette when recording.
+func (p *Processor) beginRun() func() {
+	if p.recorder == nil {
+		return func() {}
+	}
+	p.runMu.Lock()
+	p.recorder.Begin()
+
+	return func() {
+		defer p.runMu.Unlock()
+		if p.recorder.Replaying() || p.cassetteDir == "" {
+			return
+		}
+		path := filepath.Join(p.cassetteDir, fmt.Sprintf("run-%d.json", time.Now().UnixNano()))
+		if err := p.recorder.Cassette().Save(path); err != nil {
+			log.Printf("Failed to save cassette: %v", err)
+			return
+		}
+		log.Printf("Saved cassette %s", path)
+	}
+}
+
 // ProcessMessage handles the complete flow of processing a user message
 func (p *Processor) ProcessMessage(userID, message string) (*wildcard.APIResponse, error) {
 	ctx := context.Background()
+	defer p.beginRun()()
 
-	// First, interpret the message using OpenAI to determine if it's Stripe-related
-	isStripeRelated, llmResponse, err := p.openaiService.InterpretMessage(ctx, message)
+	// First, classify the message using OpenAI to determine which integration it targets
+	api, llmResponse, err := p.openaiService.ClassifyMessage(ctx, message, p.Integrations())
 	if err != nil {
-		return nil, fmt.Errorf("failed to interpret message: %w", err)
+		return nil, fmt.Errorf("failed to classify message: %w", err)
 	}
 
-	if !isStripeRelated {
+	if api == "" {
 		return &wildcard.APIResponse{
 			Success: true,
 			Data:    llmResponse,
 		}, nil
 	}
 
-	// If it is Stripe-related, use Wildcard to process it
-	return p.wildcardClient.ProcessAPIMessage(userID, message)
+	if err := p.notConfigured(userID, api); err != nil {
+		return &wildcard.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	// If it targets an integration, use Wildcard to process it
+	return p.wildcardClient.ProcessAPIMessage(userID, api, message)
 }
@@ -1,10 +1,17 @@
 package services
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	stripeapi "github.com/stripe/stripe-go/v81"
 	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/stripe"
 )
 
 func TestHandleWildcardResponse(t *testing.T) {
@@ -91,3 +98,181 @@ func TestHandleWildcardResponse(t *testing.T) {
 		})
 	}
 }
+
+type recordingExecutor struct {
+	arguments []map[string]interface{}
+}
+
+func (e *recordingExecutor) ExecuteFunction(userID string, name string, arguments map[string]interface{}) (interface{}, error) {
+	e.arguments = append(e.arguments, arguments)
+	return map[string]interface{}{"id": "cus_123"}, nil
+}
+
+func (e *recordingExecutor) Catalog() []wildcard.FunctionInfo {
+	return []wildcard.FunctionInfo{{Name: "stripe_post_customers"}}
+}
+
+// idempotentExecutor sends idempotency keys with POST functions
+type idempotentExecutor struct {
+	recordingExecutor
+}
+
+func (e *idempotentExecutor) SendsIdempotencyKey(name string) bool {
+	return strings.Contains(name, "_post_")
+}
+
+func TestHandleExecStepIdempotencyKey(t *testing.T) {
+	executor := &idempotentExecutor{}
+	client := wildcard.NewClient("http://localhost:8080")
+	client.RegisterExecutor(wildcard.APINameStripe, executor)
+
+	data := map[string]interface{}{
+		"name":      "stripe_post_customers",
+		"arguments": map[string]interface{}{"email": "jane@example.com"},
+	}
+
+	first, err := client.HandleExecStep("user123", "session1", 1, data, wildcard.APINameStripe, nil)
+	assert.NoError(t, err)
+	retry, err := client.HandleExecStep("user123", "session1", 1, data, wildcard.APINameStripe, nil)
+	assert.NoError(t, err)
+	next, err := client.HandleExecStep("user123", "session1", 2, data, wildcard.APINameStripe, nil)
+	assert.NoError(t, err)
+	corrected, err := client.HandleExecStep("user123", "session1", 1, map[string]interface{}{
+		"name":      "stripe_post_customers",
+		"arguments": map[string]interface{}{"email": "jane.doe@example.com"},
+	}, wildcard.APINameStripe, nil)
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, first.IdempotencyKey)
+	assert.Equal(t, first.IdempotencyKey, retry.IdempotencyKey)
+	assert.NotEqual(t, first.IdempotencyKey, next.IdempotencyKey)
+	assert.NotEqual(t, first.IdempotencyKey, corrected.IdempotencyKey, "a retry with corrected arguments is a new request")
+	assert.Equal(t, first.IdempotencyKey, executor.arguments[0][wildcard.ArgIdempotencyKey])
+	assert.NotContains(t, data["arguments"], wildcard.ArgIdempotencyKey, "caller's arguments must not be modified")
+
+	// Calls that send no key report none
+	read, err := client.HandleExecStep("user123", "session1", 3, map[string]interface{}{
+		"name":      "stripe_get_customers",
+		"arguments": map[string]interface{}{wildcard.ArgIdempotencyKey: "from_agent"},
+	}, wildcard.APINameStripe, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, read.IdempotencyKey)
+	assert.NotContains(t, executor.arguments[4], wildcard.ArgIdempotencyKey)
+
+	plain := &recordingExecutor{}
+	client.RegisterExecutor(wildcard.APINameGitHub, plain)
+	result, err := client.HandleExecStep("user123", "session1", 4, data, wildcard.APINameGitHub, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, result.IdempotencyKey, "executors without idempotency keys get none")
+	assert.NotContains(t, plain.arguments[0], wildcard.ArgIdempotencyKey)
+}
+
+type shapingExecutor struct {
+	recordingExecutor
+	fields []string
+}
+
+func (e *shapingExecutor) ShapeResult(name string, result interface{}, fields []string) interface{} {
+	e.fields = fields
+	return map[string]interface{}{"shaped": true}
+}
+
+func TestHandleExecStepShapesResult(t *testing.T) {
+	executor := &shapingExecutor{}
+	client := wildcard.NewClient("http://localhost:8080")
+	client.RegisterExecutor(wildcard.APINameStripe, executor)
+
+	result, err := client.HandleExecStep("user123", "session1", 1, map[string]interface{}{
+		"name": "stripe_get_customers",
+		"arguments": map[string]interface{}{
+			"limit":                    3.0,
+			wildcard.ArgResponseFields: []interface{}{"id", "email"},
+		},
+	}, wildcard.APINameStripe, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"id": "cus_123"}, result.Data)
+	assert.Equal(t, map[string]interface{}{"shaped": true}, result.AgentData())
+	assert.Equal(t, []string{"id", "email"}, executor.fields)
+	assert.NotContains(t, executor.arguments[0], wildcard.ArgResponseFields)
+}
+
+func TestHandleExecStepRehydratesArguments(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.Write([]byte(`{"id": "cus_456", "object": "customer"}`))
+	}))
+	defer server.Close()
+	stripeapi.SetBackend(stripeapi.APIBackend, stripeapi.GetBackendWithConfig(stripeapi.APIBackend, &stripeapi.BackendConfig{
+		URL:               stripeapi.String(server.URL),
+		MaxNetworkRetries: stripeapi.Int64(0),
+	}))
+	t.Cleanup(func() { stripeapi.SetBackend(stripeapi.APIBackend, nil) })
+
+	store := NewStripeKeyStore()
+	require.NoError(t, store.RegisterKey("user123", "sk_test_123"))
+	client := wildcard.NewClient("http://localhost:8080")
+	client.RegisterExecutor(wildcard.APINameStripe, stripe.NewExecutor(store))
+
+	// The agent only saw the placeholders of an earlier result
+	redaction := wildcard.NewRedactor(nil).NewRedaction()
+	redaction.Redact(map[string]interface{}{"object": "customer", "name": "Jane Doe", "email": "jane@example.com"})
+	data := map[string]interface{}{
+		"name":      "stripe_post_customers",
+		"arguments": map[string]interface{}{"name": "[NAME_1]", "email": "[EMAIL_1]"},
+	}
+
+	result, err := client.HandleExecStep("user123", "session1", 1, data, wildcard.APINameStripe, redaction)
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, "Jane Doe", form.Get("name"))
+	assert.Equal(t, "jane@example.com", form.Get("email"))
+	assert.Equal(t, "[EMAIL_1]", data["arguments"].(map[string]interface{})["email"], "caller's arguments must not be modified")
+}
+
+func TestNotConfigured(t *testing.T) {
+	processor := NewProcessor("http://localhost:8080", wildcard.NewRegistry(), nil)
+	assert.NoError(t, processor.notConfigured("user123", wildcard.APINameGitHub), "without a key store every integration is allowed")
+
+	keys := NewKeyStore()
+	assert.NoError(t, keys.RegisterKey("user123", wildcard.APINameStripe, "sk_test_123"))
+	processor.SetKeyStore(keys)
+	assert.NoError(t, processor.notConfigured("user123", wildcard.APINameStripe))
+	err := processor.notConfigured("user123", wildcard.APINameGitHub)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "the github integration is not configured")
+
+	// Integrations without authentication need no key
+	processor.Registry().Register("petstore", &publicExecutor{})
+	assert.NoError(t, processor.notConfigured("user123", "petstore"))
+}
+
+// publicExecutor is an executor for an API without authentication
+type publicExecutor struct {
+	recordingExecutor
+}
+
+func (e *publicExecutor) RequiresCredentials() bool {
+	return false
+}
+
+func TestParseClassification(t *testing.T) {
+	integrations := []Integration{{API: "stripe"}, {API: "github"}}
+	tests := []struct {
+		content  string
+		api      string
+		response string
+	}{
+		{content: "stripe This lists customers", api: "stripe", response: "This lists customers"},
+		{content: "GitHub: opens an issue", api: "github", response: "opens an issue"},
+		{content: "none Paris is the capital of France.", api: "", response: "Paris is the capital of France."},
+		{content: "Paris is the capital of France.", api: "", response: "Paris is the capital of France."},
+	}
+	for _, tt := range tests {
+		api, response := parseClassification(tt.content, integrations)
+		assert.Equal(t, tt.api, api, tt.content)
+		assert.Equal(t, tt.response, response, tt.content)
+	}
+}
@@ -11,10 +11,11 @@ import (
 
 // Event types for stream updates
 const (
-	EventStart    = "start"    // Initial event when processing starts
-	EventProgress = "progress" // Progress updates during processing
-	EventComplete = "complete" // Final success event
-	EventError    = "error"    // Error event
+	EventStart      = "start"       // Initial event when processing starts
+	EventProgress   = "progress"    // Progress updates during processing
+	EventStepFailed = "step_failed" // A function call failed; processing continues
+	EventComplete   = "complete"    // Final success event
+	EventError      = "error"       // Error event
 )
 
 // Helper functions
@@ -39,33 +40,45 @@ func handleError(updates chan<- models.StreamUpdate, msg string, err error) bool
 // StreamProcessMessage - Processes a user message, executes integrations actions if needed
 func (p *Processor) StreamProcessMessage(userID, message string, updates chan<- models.StreamUpdate) {
 	defer close(updates)
+	defer func() {
+		if r := recover(); r != nil {
+			handleError(updates, "Processing failed", fmt.Errorf("panic: %v", r))
+		}
+	}()
+	defer p.beginRun()()
 
 	// Start processing
 	send(updates, EventStart, map[string]interface{}{
 		"message": "Starting message processing",
 	})
 
-	// Step 1: Process with OpenAI to determine if the given action is related to an integration
+	// Step 1: Process with OpenAI to determine which integration, if any, the message targets
 	send(updates, EventProgress, map[string]interface{}{
 		"message": "Analyzing message with OpenAI",
 	})
 
-	isStripeRelated, llmResponse, err := p.openaiService.InterpretMessage(context.Background(), message)
+	api, llmResponse, err := p.openaiService.ClassifyMessage(context.Background(), message, p.Integrations())
 	if err != nil {
 		handleError(updates, "Failed to process with OpenAI", err)
 		return
 	}
 
-	if !isStripeRelated {
+	if api == "" {
 		send(updates, EventComplete, map[string]interface{}{
 			"message": llmResponse,
 		})
 		return
 	}
 
-	// Step 2: Create Wildcard session since we know the action is related to Stripe
+	if err := p.notConfigured(userID, api); err != nil {
+		handleError(updates, "Integration not configured", err)
+		return
+	}
+
+	// Step 2: Create Wildcard session since we know the action targets an integration
 	send(updates, EventProgress, map[string]interface{}{
-		"message": "Creating Wildcard session",
+		"message":     "Creating Wildcard session",
+		"integration": api,
 	})
 
 	sessionID, err := p.wildcardClient.CreateSession(userID)
@@ -77,6 +90,10 @@ func (p *Processor) StreamProcessMessage(userID, message string, updates chan<-
 	var actionResults []string
 	currentMessage := message
 
+	// PII in function results is replaced by placeholders before it reaches Wildcard
+	// or OpenAI, and restored in the final response
+	redaction := p.wildcardClient.NewRedaction()
+
 	for {
 		send(updates, EventProgress, map[string]interface{}{
 			"message": "Processing with Wildcard",
@@ -89,25 +106,42 @@ func (p *Processor) StreamProcessMessage(userID, message string, updates chan<-
 
 		switch resp.Event {
 		case wildcard.EventExec:
-			// Step 4: Execute the function since we have an available action
-			result, _ := p.wildcardClient.HandleExecEvent(userID, resp.Data, resp.API)
+			// Step 4: Execute the function since we have an available action, unless it
+			// belongs to another integration than the message was classified for. A failed
+			// step keeps its number so a retry of the same call reuses its idempotency key,
+			// while a retry with corrected arguments gets a new one.
+			result := wildcard.CheckAPI(resp.Data, resp.API, api)
+			if result == nil {
+				result, _ = p.wildcardClient.HandleExecStep(userID, sessionID, len(actionResults)+1, resp.Data, resp.API, redaction)
+			}
 
 			if !result.Success {
-				handleError(updates, "Failed to execute function", fmt.Errorf("function execution failed"))
-				currentMessage = fmt.Sprintf("Failed to execute function '%s'. Received Response: %v", resp.Data["name"], result.Error)
+				send(updates, EventStepFailed, map[string]interface{}{
+					"message": "Failed to execute function",
+					"error":   result.Error,
+					"details": result.ErrorDetails,
+				})
+				currentMessage = fmt.Sprintf("Failed to execute function '%s'. Received Response: %v", resp.Data["name"], result.FailureMessage(redaction))
 				continue
 			}
 
-			send(updates, EventProgress, map[string]interface{}{
+			progress := map[string]interface{}{
 				"message": fmt.Sprintf("Ran %s successfully", resp.Data["name"]),
 				"result":  result.Data,
-			})
+			}
+			// Only calls that sent an idempotency key report one
+			if result.IdempotencyKey != "" {
+				progress["idempotency_key"] = result.IdempotencyKey
+			}
+			send(updates, EventProgress, progress)
 
-			// Marshal the data into JSON and add prefix with function name and response
-			dataBytes, err := json.Marshal(result.Data)
+			// Marshal the shaped, redacted data into JSON and add prefix with function name and
+			// response. The progress event above carries the full result.
+			agentData := redaction.Redact(result.AgentData())
+			dataBytes, err := json.Marshal(agentData)
 			if err != nil {
 				// Fallback: just use fmt.Sprintf
-				currentMessage = fmt.Sprintf("Successfully executed function '%s'. Received Response: %v", resp.Data["name"], result.Data)
+				currentMessage = fmt.Sprintf("Successfully executed function '%s'. Received Response: %v", resp.Data["name"], agentData)
 			} else {
 				currentMessage = fmt.Sprintf("Successfully executed function '%s'. Received Response: %s", resp.Data["name"], string(dataBytes))
 			}
@@ -147,8 +181,8 @@ func (p *Processor) StreamProcessMessage(userID, message string, updates chan<-
 
 			// Send the final response with the OpenAI-generated summary
 			send(updates, EventComplete, map[string]interface{}{
-				"message": summary,
-				"data":    data, // Include original data as well
+				"message": redaction.Rehydrate(summary),
+				"data":    redaction.RehydrateValue(data), // Include original data as well
 			})
 			return
 
@@ -158,7 +192,10 @@ func (p *Processor) StreamProcessMessage(userID, message string, updates chan<-
 				handleError(updates, "Failed to handle Wildcard error", err)
 				return
 			}
-			handleError(updates, wildcardResp.Error, nil)
+			send(updates, EventError, map[string]interface{}{
+				"message": "Wildcard returned an error",
+				"error":   redaction.Rehydrate(wildcardResp.Error),
+			})
 			return
 
 		default:
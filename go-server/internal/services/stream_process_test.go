@@ -0,0 +1,67 @@
+package services
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	stripeapi "github.com/stripe/stripe-go/v81"
+	"github.com/wildcard-lovable/go-server/internal/cassette"
+	"github.com/wildcard-lovable/go-server/internal/models"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/stripe"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// TestStreamProcessGolden replays the cassettes in testdata/cassettes, recorded
+// with CASSETTE_DIR, and compares the stream updates with testdata/golden.
+func TestStreamProcessGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		message string
+	}{
+		{name: "list_customers", userID: "user123", message: "List my customers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := cassette.Load(filepath.Join("testdata", "cassettes", tt.name+".json"))
+			require.NoError(t, err)
+			recorder := cassette.NewReplayer(c)
+
+			stripe.UseHTTPClient(recorder.Client())
+			t.Cleanup(func() { stripeapi.SetBackend(stripeapi.APIBackend, nil) })
+			store := NewStripeKeyStore()
+			require.NoError(t, store.RegisterKey(tt.userID, "sk_test_123"))
+			openaiService := NewOpenAIService("test", option.WithHTTPClient(recorder.Client()))
+			registry := wildcard.NewRegistry()
+			registry.Register(wildcard.APINameStripe, stripe.NewExecutor(store))
+			processor := NewProcessor("http://localhost:8000", registry, openaiService)
+			processor.SetRecorder(recorder, "")
+
+			updates := make(chan models.StreamUpdate)
+			go processor.StreamProcessMessage(tt.userID, tt.message, updates)
+			var got []models.StreamUpdate
+			for u := range updates {
+				got = append(got, u)
+			}
+			gotJSON, err := json.MarshalIndent(got, "", "  ")
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", "golden", tt.name+".json")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, append(gotJSON, '\n'), 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(gotJSON))
+		})
+	}
+}
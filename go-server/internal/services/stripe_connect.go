@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/oauth"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// ConnectTokenLifetime is how long the access token of a connected account is
+// used before it is rolled with its refresh token. Stripe does not expire
+// Connect access tokens, so the server rolls them itself.
+const ConnectTokenLifetime = 24 * time.Hour
+
+// StripeConnect runs the Stripe Connect OAuth flow of the platform, through
+// which users connect their Stripe account instead of pasting a secret key
+type StripeConnect struct {
+	clientID    string
+	secretKey   string
+	redirectURL string
+	baseURL     string
+	client      oauth.Client
+}
+
+// NewStripeConnect creates a Stripe Connect OAuth client for the platform's
+// client ID and secret key. baseURL serves the authorize, token and
+// deauthorize endpoints; it defaults to https://connect.stripe.com.
+func NewStripeConnect(clientID, secretKey, redirectURL, baseURL string) *StripeConnect {
+	if baseURL == "" {
+		baseURL = stripe.ConnectURL
+	}
+	c := &StripeConnect{
+		clientID:    clientID,
+		secretKey:   secretKey,
+		redirectURL: redirectURL,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+	}
+	c.SetHTTPClient(nil)
+	return c
+}
+
+// SetHTTPClient sets the HTTP client used for requests to the token
+// endpoints, e.g. to record or replay them. A nil client uses the default.
+func (c *StripeConnect) SetHTTPClient(client *http.Client) {
+	c.client = oauth.Client{
+		B: stripe.GetBackendWithConfig(stripe.ConnectBackend, &stripe.BackendConfig{
+			URL:        stripe.String(c.baseURL),
+			HTTPClient: client,
+		}),
+		Key: c.secretKey,
+	}
+}
+
+// AuthorizeURL returns the URL the user is sent to to connect their account.
+// Stripe redirects back to the redirect URL with the state and a code.
+func (c *StripeConnect) AuthorizeURL(state string) string {
+	params := &stripe.AuthorizeURLParams{
+		ClientID:     stripe.String(c.clientID),
+		ResponseType: stripe.String("code"),
+		Scope:        stripe.String(string(stripe.OAuthScopeTypeReadWrite)),
+		State:        stripe.String(state),
+	}
+	if c.redirectURL != "" {
+		params.RedirectURI = stripe.String(c.redirectURL)
+	}
+	return strings.Replace(c.client.AuthorizeURL(params), stripe.ConnectURL, c.baseURL, 1)
+}
+
+// Exchange exchanges an authorization code for a credential of the connected
+// account, named after and bound to the account. The platform's secret key is
+// never handed out as a user's credential, so a response without an access
+// token is an error.
+func (c *StripeConnect) Exchange(code string) (wildcard.Credential, error) {
+	token, err := c.client.New(&stripe.OAuthTokenParams{
+		ClientSecret: stripe.String(c.secretKey),
+		Code:         stripe.String(code),
+		GrantType:    stripe.String("authorization_code"),
+	})
+	if err != nil {
+		return wildcard.Credential{}, fmt.Errorf("failed to exchange the authorization code: %v", err)
+	}
+	if token.StripeUserID == "" {
+		return wildcard.Credential{}, fmt.Errorf("the token response has no connected account")
+	}
+	if token.AccessToken == "" {
+		return wildcard.Credential{}, fmt.Errorf("the token response has no access token for %s", token.StripeUserID)
+	}
+
+	return wildcard.Credential{
+		Name:         token.StripeUserID,
+		Secret:       token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    time.Now().Add(ConnectTokenLifetime),
+		Account:      token.StripeUserID,
+	}, nil
+}
+
+// Refresh rolls the access token of a connected account with its refresh
+// token. It is the KeyStore refresher of Stripe credentials.
+func (c *StripeConnect) Refresh(credential wildcard.Credential) (wildcard.Credential, error) {
+	token, err := c.client.New(&stripe.OAuthTokenParams{
+		ClientSecret: stripe.String(c.secretKey),
+		GrantType:    stripe.String("refresh_token"),
+		RefreshToken: stripe.String(credential.RefreshToken),
+	})
+	if err != nil {
+		return wildcard.Credential{}, fmt.Errorf("failed to refresh the access token of %s: %v", credential.Account, err)
+	}
+	if token.AccessToken == "" {
+		return wildcard.Credential{}, fmt.Errorf("the token response has no access token for %s", credential.Account)
+	}
+	if token.StripeUserID != "" && token.StripeUserID != credential.Account {
+		return wildcard.Credential{}, fmt.Errorf("the refreshed token is for %s, not %s", token.StripeUserID, credential.Account)
+	}
+
+	return wildcard.Credential{
+		Secret:       token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    time.Now().Add(ConnectTokenLifetime),
+	}, nil
+}
+
+// Revoke disconnects a connected account from the platform
+func (c *StripeConnect) Revoke(account string) error {
+	_, err := c.client.Del(&stripe.DeauthorizeParams{
+		ClientID:     stripe.String(c.clientID),
+		StripeUserID: stripe.String(account),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke access to %s: %v", account, err)
+	}
+	return nil
+}
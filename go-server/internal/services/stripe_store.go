@@ -1,60 +1,37 @@
 package services
 
 import (
-	"fmt"
-	"sync"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
 )
 
-// StripeKeyStore manages Stripe API keys for users
+// StripeKeyStore manages Stripe API keys for users. It is a view of the Stripe
+// credentials in a KeyStore.
 type StripeKeyStore struct {
-	keys map[string]string // userID -> stripeAPIKey
-	mu   sync.RWMutex
+	keys *KeyStore
 }
 
-// NewStripeKeyStore creates a new StripeKeyStore
+// NewStripeKeyStore creates a new StripeKeyStore backed by its own KeyStore
 func NewStripeKeyStore() *StripeKeyStore {
-	return &StripeKeyStore{
-		keys: make(map[string]string),
-	}
+	return NewKeyStore().Stripe()
 }
 
-// RegisterKey registers a Stripe API key for a user
+// RegisterKey registers a Stripe API key for a user as their default credential
 func (s *StripeKeyStore) RegisterKey(userID, apiKey string) error {
-	if userID == "" || apiKey == "" {
-		return fmt.Errorf("userID and apiKey cannot be empty")
-	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.keys[userID] = apiKey
-	return nil
+	return s.keys.RegisterKey(userID, wildcard.APINameStripe, apiKey)
 }
 
-// GetStripeKey retrieves a user's Stripe API key
+// GetStripeKey retrieves a user's default Stripe API key
 func (s *StripeKeyStore) GetStripeKey(userID string) (string, error) {
-	if userID == "" {
-		return "", fmt.Errorf("userID cannot be empty")
-	}
-
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.keys.GetKey(userID, wildcard.APINameStripe)
+}
 
-	key, exists := s.keys[userID]
-	if !exists {
-		return "", fmt.Errorf("no Stripe API key found for user %s", userID)
-	}
-	return key, nil
+// StripeCredential retrieves a user's Stripe credential by name, or their
+// default credential if name is empty
+func (s *StripeKeyStore) StripeCredential(userID, name string) (*wildcard.Credential, error) {
+	return s.keys.Credential(userID, wildcard.APINameStripe, name)
 }
 
-// RemoveKey removes a user's Stripe API key
+// RemoveKey removes a user's Stripe API keys
 func (s *StripeKeyStore) RemoveKey(userID string) error {
-	if userID == "" {
-		return fmt.Errorf("userID cannot be empty")
-	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	delete(s.keys, userID)
-	return nil
+	return s.keys.RemoveKey(userID, wildcard.APINameStripe)
 }
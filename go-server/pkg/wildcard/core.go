@@ -2,39 +2,109 @@ package wildcard
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 // Executor is the interface that all integration executors must implement
 type Executor interface {
 	ExecuteFunction(userID string, name string, arguments map[string]interface{}) (interface{}, error)
+
+	// Catalog lists the functions the executor supports
+	Catalog() []FunctionInfo
+}
+
+// DetailedError is implemented by executor errors that carry structured details,
+// such as the type and code of an API error
+type DetailedError interface {
+	error
+	Details() map[string]interface{}
+}
+
+// ResultShaper is implemented by executors that can reduce a function result to
+// the fields the agent needs. Fields lists the fields Wildcard asked for, if any.
+type ResultShaper interface {
+	ShapeResult(name string, result interface{}, fields []string) interface{}
+}
+
+// Describer is implemented by executors that describe what their integration
+// is for, used to choose which integration a message targets
+type Describer interface {
+	Description() string
+}
+
+// IdempotentExecutor is implemented by executors that send idempotency keys to
+// their API. Only calls of functions for which SendsIdempotencyKey is true get
+// a key.
+type IdempotentExecutor interface {
+	SendsIdempotencyKey(name string) bool
+}
+
+// CredentialRequirer is implemented by executors that can run without a
+// credential of the user, such as public APIs. Executors that do not implement
+// it require one.
+type CredentialRequirer interface {
+	RequiresCredentials() bool
 }
 
 // Client handles core Wildcard operations
 type Client struct {
-	baseURL   string
-	executors map[string]Executor
+	baseURL    string
+	httpClient *http.Client
+	registry   *Registry
+	redactor   *Redactor
 }
 
-// NewClient creates a new Wildcard client
+// NewClient creates a new Wildcard client with an empty registry
 func NewClient(baseURL string) *Client {
+	return NewClientWithRegistry(baseURL, NewRegistry())
+}
+
+// NewClientWithRegistry creates a new Wildcard client executing functions with
+// the executors of a registry
+func NewClientWithRegistry(baseURL string, registry *Registry) *Client {
 	return &Client{
-		baseURL:   baseURL,
-		executors: make(map[string]Executor),
+		baseURL:    baseURL,
+		httpClient: http.DefaultClient,
+		registry:   registry,
 	}
 }
 
+// Registry returns the registry of the client's executors
+func (c *Client) Registry() *Registry {
+	return c.registry
+}
+
 // RegisterExecutor registers an executor for a specific API
 func (c *Client) RegisterExecutor(apiName string, executor Executor) {
-	c.executors[apiName] = executor
+	c.registry.Register(apiName, executor)
+}
+
+// SetHTTPClient sets the HTTP client used for requests to the Wildcard backend
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.httpClient = client
+}
+
+// SetRedactor sets the redactor applied to function results before they are sent
+// back to Wildcard. A nil redactor disables redaction.
+func (c *Client) SetRedactor(redactor *Redactor) {
+	c.redactor = redactor
+}
+
+// NewRedaction starts the redaction of a run, or returns nil when redaction is disabled
+func (c *Client) NewRedaction() *Redaction {
+	return c.redactor.NewRedaction()
 }
 
 // CreateSession creates a new session for the user
 func (c *Client) CreateSession(userID string) (string, error) {
 	url := fmt.Sprintf("%s/session/%s", c.baseURL, userID)
-	resp, err := http.Post(url, "application/json", nil)
+	resp, err := c.httpClient.Post(url, "application/json", nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
@@ -62,7 +132,7 @@ func (c *Client) ProcessMessage(userID, sessionID, message string) (*Response, e
 		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := c.httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to process message: %w", err)
 	}
@@ -140,7 +210,7 @@ func (c *Client) HandleExecEvent(userID string, data map[string]interface{}, api
 	}
 
 	// Get the executor for this API
-	executor, ok := c.executors[apiName]
+	executor, ok := c.registry.Executor(apiName)
 	if !ok {
 		return &APIResponse{
 			Success: false,
@@ -148,23 +218,164 @@ func (c *Client) HandleExecEvent(userID string, data map[string]interface{}, api
 		}, nil
 	}
 
+	// Requested response fields are for shaping the result, not for the executor
+	fields, hasFields := function.Arguments[ArgResponseFields]
+	if hasFields {
+		arguments := make(map[string]interface{}, len(function.Arguments))
+		for k, v := range function.Arguments {
+			if k != ArgResponseFields {
+				arguments[k] = v
+			}
+		}
+		function.Arguments = arguments
+	}
+
 	// Execute the function
 	result, err := executor.ExecuteFunction(userID, function.Name, function.Arguments)
 	if err != nil {
-		return &APIResponse{
+		resp := &APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("We tried to execute function '%s', but received error: %v", name, err),
-		}, nil
+		}
+		var detailed DetailedError
+		if errors.As(err, &detailed) {
+			resp.ErrorDetails = detailed.Details()
+		}
+		return resp, nil
 	}
 
-	return &APIResponse{
+	resp := &APIResponse{
 		Success: true,
 		Data:    result,
-	}, nil
+	}
+	if shaper, ok := executor.(ResultShaper); ok {
+		resp.Shaped = shaper.ShapeResult(function.Name, result, responseFields(fields))
+	}
+	return resp, nil
+}
+
+// responseFields reads the response_fields argument, given either as a list or
+// as a comma separated string
+func responseFields(value interface{}) []string {
+	var fields []string
+	switch v := value.(type) {
+	case string:
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	case []string:
+		fields = v
+	case []interface{}:
+		for _, item := range v {
+			if field, ok := item.(string); ok && field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// AgentData returns the result to pass back to Wildcard: the shaped result when
+// the executor provided one, otherwise the full data
+func (r *APIResponse) AgentData() interface{} {
+	if r.Shaped != nil {
+		return r.Shaped
+	}
+	return r.Data
+}
+
+// FailureMessage describes a failed function call for Wildcard, including the
+// structured error details when the executor provided them. PII in the error
+// and its details is replaced by the run's placeholders.
+func (r *APIResponse) FailureMessage(redaction *Redaction) string {
+	msg := redaction.RedactText(r.Error)
+	if len(r.ErrorDetails) == 0 {
+		return msg
+	}
+	details, err := json.Marshal(redaction.Redact(r.ErrorDetails))
+	if err != nil {
+		return msg
+	}
+	return fmt.Sprintf("%s. Error details: %s", msg, redaction.RedactText(string(details)))
 }
 
-// ProcessAPIMessage handles the complete flow of processing an API-specific message
-func (c *Client) ProcessAPIMessage(userID, message string) (*APIResponse, error) {
+// IdempotencyKey derives the idempotency key for a function call from the run,
+// step, function name and arguments. Retrying the same call at the same step
+// reuses the key, while a call with corrected arguments gets a new one so that
+// Stripe neither replays the earlier result nor rejects the new parameters.
+func IdempotencyKey(runID string, step int, function string, arguments map[string]interface{}) string {
+	callArguments := make(map[string]interface{}, len(arguments))
+	for k, v := range arguments {
+		if k != ArgIdempotencyKey && k != ArgResponseFields {
+			callArguments[k] = v
+		}
+	}
+	// Maps are encoded with sorted keys, so equal arguments hash equally
+	encoded, _ := json.Marshal(callArguments)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s:%s", runID, step, function, encoded)))
+	return "wildcard-" + hex.EncodeToString(sum[:16])
+}
+
+// HandleExecStep executes an EXEC event as a step of a run. Placeholders from the
+// run's redaction are replaced by the original values in the arguments. When the
+// executor sends idempotency keys for the function, it receives the key of the
+// call in the ArgIdempotencyKey argument and the key is reported in the result.
+func (c *Client) HandleExecStep(userID, runID string, step int, data map[string]interface{}, apiName string, redaction *Redaction) (*APIResponse, error) {
+	name, _ := data["name"].(string)
+	arguments, ok := data["arguments"].(map[string]interface{})
+	if name == "" || !ok {
+		return c.HandleExecEvent(userID, data, apiName)
+	}
+	if rehydrated, ok := redaction.RehydrateValue(arguments).(map[string]interface{}); ok {
+		arguments = rehydrated
+	}
+
+	var key string
+	if executor, ok := c.registry.Executor(apiName); ok {
+		if idempotent, ok := executor.(IdempotentExecutor); ok && idempotent.SendsIdempotencyKey(name) {
+			key = IdempotencyKey(runID, step, name, arguments)
+		}
+	}
+	stepArguments := make(map[string]interface{}, len(arguments)+1)
+	for k, v := range arguments {
+		if k != ArgIdempotencyKey {
+			stepArguments[k] = v
+		}
+	}
+	if key != "" {
+		stepArguments[ArgIdempotencyKey] = key
+	}
+
+	stepData := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		stepData[k] = v
+	}
+	stepData["arguments"] = stepArguments
+
+	result, err := c.HandleExecEvent(userID, stepData, apiName)
+	if result != nil {
+		result.IdempotencyKey = key
+	}
+	return result, err
+}
+
+// CheckAPI returns a failed response for an EXEC event whose API is not the
+// integration the run was classified for, or nil if it is
+func CheckAPI(data map[string]interface{}, apiName, runAPI string) *APIResponse {
+	if apiName == runAPI {
+		return nil
+	}
+	return &APIResponse{
+		Success: false,
+		Error:   fmt.Sprintf("We tried to execute function '%v', but it belongs to the %q API and this request can only use the %s integration", data["name"], apiName, runAPI),
+	}
+}
+
+// ProcessAPIMessage handles the complete flow of processing a message that
+// targets an integration. Functions of other integrations are not executed.
+func (c *Client) ProcessAPIMessage(userID, apiName, message string) (*APIResponse, error) {
 	// Create a session
 	sessionID, err := c.CreateSession(userID)
 	if err != nil {
@@ -173,6 +384,8 @@ func (c *Client) ProcessAPIMessage(userID, message string) (*APIResponse, error)
 
 	// Process messages with Wildcard until we get a final response
 	currentMessage := message
+	step := 1
+	redaction := c.NewRedaction()
 	for {
 		resp, err := c.ProcessMessage(userID, sessionID, currentMessage)
 		if err != nil {
@@ -181,19 +394,29 @@ func (c *Client) ProcessAPIMessage(userID, message string) (*APIResponse, error)
 
 		// For EXEC events, execute the function and continue the conversation
 		if resp.Event == EventExec {
-			result, _ := c.HandleExecEvent(userID, resp.Data, resp.API)
+			result := CheckAPI(resp.Data, resp.API, apiName)
+			if result == nil {
+				result, _ = c.HandleExecStep(userID, sessionID, step, resp.Data, resp.API, redaction)
+			}
 			if !result.Success {
 				// Send the error message back to continue the conversation
-				currentMessage = result.Error
+				currentMessage = result.FailureMessage(redaction)
 				continue
 			}
+			step++
 
 			// Format as a descriptive string message
-			currentMessage = fmt.Sprintf("The %s operation was successful. The result was: %v", resp.Data["name"], result.Data)
+			currentMessage = fmt.Sprintf("The %s operation was successful. The result was: %v", resp.Data["name"], redaction.Redact(result.AgentData()))
 			continue
 		}
 
-		// For all other events, handle the response and return
-		return c.HandleResponse(resp)
+		// For all other events, handle the response and return it with PII restored
+		final, err := c.HandleResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		final.Data = redaction.RehydrateValue(final.Data)
+		final.Error = redaction.Rehydrate(final.Error)
+		return final, nil
 	}
 }
@@ -0,0 +1,27 @@
+package wildcard
+
+import "time"
+
+// ExpiryLeeway is how long before its expiry a credential is treated as
+// expired, so that it is not used for a request that outlives it
+const ExpiryLeeway = time.Minute
+
+// Credential is a named credential of a user for an integration, such as an
+// API key or a refreshable OAuth token
+type Credential struct {
+	Name         string
+	Secret       string    // API key or OAuth access token
+	RefreshToken string    // OAuth refresh token, if the secret can be refreshed
+	ExpiresAt    time.Time // when the secret expires, zero if it does not
+	Account      string    // account the secret acts on, e.g. a Stripe Connect account ID
+}
+
+// Expired reports whether the credential has expired at now, or will within ExpiryLeeway
+func (c Credential) Expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt.Add(-ExpiryLeeway))
+}
+
+// Refreshable reports whether the credential can be refreshed
+func (c Credential) Refreshable() bool {
+	return c.RefreshToken != ""
+}
@@ -0,0 +1,238 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the public GitHub REST API
+const DefaultBaseURL = "https://api.github.com"
+
+// MaxListItems is the per_page sent with list calls that ask for no more than
+// GitHub's maximum of 100 items
+var MaxListItems = 100
+
+// Executor handles GitHub REST API operations
+type Executor struct {
+	tokenStore TokenStore
+	baseURL    string
+	httpClient *http.Client
+}
+
+// TokenStore retrieves users' GitHub personal access or app installation
+// tokens by credential name
+type TokenStore = rest.TokenStore
+
+// NewExecutor creates a new GitHub executor for the API at baseURL, or the
+// public GitHub API if baseURL is empty
+func NewExecutor(tokenStore TokenStore, baseURL string) *Executor {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Executor{
+		tokenStore: tokenStore,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetHTTPClient sets the HTTP client used for GitHub API requests, such as the
+// client of a cassette recorder
+func (e *Executor) SetHTTPClient(client *http.Client) {
+	e.httpClient = client
+}
+
+// Description tells the classifier which requests GitHub handles
+func (e *Executor) Description() string {
+	return "GitHub repositories: issues, comments, pull requests, merges and releases"
+}
+
+// Catalog lists the supported GitHub operations. GET operations are read-only.
+func (e *Executor) Catalog() []wildcard.FunctionInfo {
+	ops := Operations()
+	catalog := make([]wildcard.FunctionInfo, 0, len(ops))
+	for name, op := range ops {
+		catalog = append(catalog, wildcard.FunctionInfo{
+			Name:        name,
+			Description: op.Description,
+			Arguments:   op.Arguments(),
+			ReadOnly:    op.Method == http.MethodGet,
+		})
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+	return catalog
+}
+
+// ListPage is a single page of list results. NextPage is passed back as page
+// to fetch the next one.
+type ListPage struct {
+	Object   string        `json:"object"`
+	Data     []interface{} `json:"data"`
+	HasMore  bool          `json:"has_more"`
+	NextPage int           `json:"next_page,omitempty"`
+}
+
+// APIError is an error response from the GitHub API
+type APIError struct {
+	StatusCode       int
+	Message          string
+	DocumentationURL string
+	Errors           []interface{}
+	RequestID        string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitHub API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Details returns the structured details of the error
+func (e *APIError) Details() map[string]interface{} {
+	details := map[string]interface{}{
+		"message": e.Message,
+		"status":  e.StatusCode,
+	}
+	if e.DocumentationURL != "" {
+		details["documentation_url"] = e.DocumentationURL
+	}
+	if len(e.Errors) > 0 {
+		details["errors"] = e.Errors
+	}
+	if e.RequestID != "" {
+		details["request_id"] = e.RequestID
+	}
+	return details
+}
+
+// ExecuteFunction executes a GitHub function by name with given arguments.
+// Path parameters are filled from the arguments; the remaining arguments are
+// sent as the query string of GET and DELETE requests, or as the JSON body.
+func (e *Executor) ExecuteFunction(userID string, name string, args map[string]interface{}) (interface{}, error) {
+	op, exists := Operations()[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown function: %s", name)
+	}
+
+	token, err := rest.Token(e.tokenStore, userID, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GitHub token for user %s: %v", userID, err)
+	}
+
+	params := rest.Params(args)
+	required := pathParams(op.Path)
+	for _, p := range op.Params {
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	if err := rest.RequireArgs(params, required); err != nil {
+		return nil, err
+	}
+
+	path := op.Path
+	for _, param := range pathParams(op.Path) {
+		path = strings.Replace(path, "{"+param+"}", url.PathEscape(rest.ArgString(params[param])), 1)
+		delete(params, param)
+	}
+	if op.List {
+		if perPage, err := strconv.Atoi(rest.ArgString(params["per_page"])); err != nil || perPage < 1 || perPage > MaxListItems {
+			params["per_page"] = MaxListItems
+		}
+	}
+
+	req, err := e.newRequest(op.Method, path, params, token)
+	if err != nil {
+		return nil, err
+	}
+	resp, body, err := rest.Do(e.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub request failed: %v", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError(resp, body)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		// e.g. 204 No Content after a delete
+		return map[string]interface{}{"status": resp.StatusCode}, nil
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub response: %v", err)
+	}
+	if items, ok := result.([]interface{}); ok && op.List {
+		page := &ListPage{Object: "list", Data: items}
+		page.NextPage = nextPage(resp.Header.Get("Link"))
+		page.HasMore = page.NextPage > 0
+		return page, nil
+	}
+	return result, nil
+}
+
+// newRequest builds a request for the path, sending params as the query string
+// of GET and DELETE requests and as the JSON body otherwise
+func (e *Executor) newRequest(method, path string, params map[string]interface{}, token string) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if method == http.MethodGet || method == http.MethodDelete {
+		req, err = rest.NewRequest(method, e.baseURL+path, rest.Query(params), nil, token)
+	} else {
+		req, err = rest.NewRequest(method, e.baseURL+path, nil, params, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", "wildcard-lovable")
+	return req, nil
+}
+
+var nextLinkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPage returns the page number of the rel="next" link of a Link header,
+// or 0 if there is none
+func nextPage(link string) int {
+	match := nextLinkPattern.FindStringSubmatch(link)
+	if match == nil {
+		return 0
+	}
+	u, err := url.Parse(match[1])
+	if err != nil {
+		return 0
+	}
+	page, _ := strconv.Atoi(u.Query().Get("page"))
+	return page
+}
+
+// newAPIError decodes a GitHub error response
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-GitHub-Request-Id"),
+	}
+	var payload struct {
+		Message          string        `json:"message"`
+		DocumentationURL string        `json:"documentation_url"`
+		Errors           []interface{} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Message != "" {
+		apiErr.Message = payload.Message
+		apiErr.DocumentationURL = payload.DocumentationURL
+		apiErr.Errors = payload.Errors
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
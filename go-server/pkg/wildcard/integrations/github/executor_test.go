@@ -0,0 +1,143 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/internal/rest/resttest"
+)
+
+func TestExecuteFunction(t *testing.T) {
+	server := resttest.NewServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=3&per_page=100>; rel="next", <http://x/?page=9>; rel="last"`, "http://"+r.Host, r.URL.Path))
+			fmt.Fprint(w, `[{"number": 1, "title": "Bug"}]`)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"number": 2, "title": "New issue"}`)
+		}
+	})
+	executor := NewExecutor(resttest.TokenStore{"user123": "ghp_123"}, server.URL)
+
+	result, err := executor.ExecuteFunction("user123", "github_post_repos_owner_repo_issues", map[string]interface{}{
+		"owner":                    "octo",
+		"repo":                     "hello world",
+		"title":                    "New issue",
+		"labels":                   []interface{}{"bug"},
+		wildcard.ArgIdempotencyKey: "key_123",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"number": float64(2), "title": "New issue"}, result)
+	requests := server.Requests()
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Equal(t, "/repos/octo/hello world/issues", requests[0].Path)
+	assert.Equal(t, "Bearer ghp_123", requests[0].Auth())
+	assert.Equal(t, "application/vnd.github+json", requests[0].Header.Get("Accept"))
+	assert.JSONEq(t, `{"title": "New issue", "labels": ["bug"]}`, requests[0].Body)
+
+	result, err = executor.ExecuteFunction("user123", "github_get_repos_owner_repo_issues", map[string]interface{}{
+		"owner":  "octo",
+		"repo":   "hello",
+		"state":  "open",
+		"labels": []interface{}{"bug", "ui"},
+		"page":   float64(2),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &ListPage{
+		Object:   "list",
+		Data:     []interface{}{map[string]interface{}{"number": float64(1), "title": "Bug"}},
+		HasMore:  true,
+		NextPage: 3,
+	}, result)
+	requests = server.Requests()
+	assert.Equal(t, "/repos/octo/hello/issues", requests[1].Path)
+	assert.Equal(t, "labels=bug%2Cui&page=2&per_page=100&state=open", requests[1].Query)
+
+	result, err = executor.ExecuteFunction("user123", "github_delete_repos_owner_repo_releases_release_id", map[string]interface{}{
+		"owner": "octo", "repo": "hello", "release_id": float64(123456789),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"status": http.StatusNoContent}, result)
+	assert.Equal(t, "/repos/octo/hello/releases/123456789", server.Requests()[2].Path)
+
+	// A named credential is used instead of the default token
+	executor.tokenStore = resttest.TokenStore{"user123": "ghp_123", "user123/work": "ghp_work"}
+	_, err = executor.ExecuteFunction("user123", "github_patch_repos_owner_repo_issues_issue_number", map[string]interface{}{
+		"owner": "octo", "repo": "hello", "issue_number": float64(1), "state": "closed",
+		wildcard.ArgCredential: "work",
+	})
+	require.NoError(t, err)
+	requests = server.Requests()
+	assert.Equal(t, "Bearer ghp_work", requests[3].Auth())
+	assert.Equal(t, map[string]interface{}{"state": "closed"}, requests[3].JSON(t))
+}
+
+func TestExecuteFunctionErrors(t *testing.T) {
+	server := resttest.NewServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-GitHub-Request-Id", "req_123")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprint(w, `{"message": "Validation Failed", "errors": [{"field": "tag_name", "code": "already_exists"}], "documentation_url": "https://docs.github.com"}`)
+	})
+	executor := NewExecutor(resttest.TokenStore{"user123": "ghp_123"}, server.URL)
+
+	_, err := executor.ExecuteFunction("user123", "github_post_repos_owner_repo_releases", map[string]interface{}{
+		"owner": "octo", "repo": "hello", "tag_name": "v1.0.0",
+	})
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, map[string]interface{}{
+		"message":           "Validation Failed",
+		"status":            http.StatusUnprocessableEntity,
+		"documentation_url": "https://docs.github.com",
+		"errors":            []interface{}{map[string]interface{}{"field": "tag_name", "code": "already_exists"}},
+		"request_id":        "req_123",
+	}, apiErr.Details())
+
+	_, err = executor.ExecuteFunction("user123", "github_post_repos_owner_repo_pulls", map[string]interface{}{
+		"owner": "octo", "title": "Fix",
+	})
+	require.Error(t, err)
+	assert.Equal(t, "missing required arguments: repo, head, base", err.Error())
+
+	_, err = executor.ExecuteFunction("user123", "github_post_repos_owner_repo_pulls", map[string]interface{}{
+		"owner": "octo", "repo": "", "title": "Fix", "head": "fix", "base": "main",
+	})
+	require.Error(t, err)
+	assert.Equal(t, "missing required arguments: repo", err.Error(), "empty path parameters are missing")
+
+	_, err = executor.ExecuteFunction("other", "github_get_user_repos", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get GitHub token")
+
+	_, err = executor.ExecuteFunction("user123", "github_delete_repos_owner_repo", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown function")
+	assert.Len(t, server.Requests(), 1, "invalid calls are not sent")
+}
+
+func TestCatalog(t *testing.T) {
+	catalog := NewExecutor(resttest.TokenStore{}, "").Catalog()
+	require.Len(t, catalog, len(operations))
+
+	byName := make(map[string]wildcard.FunctionInfo)
+	for _, fn := range catalog {
+		byName[fn.Name] = fn
+	}
+	getIssue := byName["github_get_repos_owner_repo_issues_issue_number"]
+	assert.True(t, getIssue.ReadOnly)
+	assert.Equal(t, []interface{}{"owner", "repo", "issue_number"}, getIssue.Arguments["required"])
+
+	createRelease := byName["github_post_repos_owner_repo_releases"]
+	assert.False(t, createRelease.ReadOnly)
+	assert.Equal(t, "Create a release", createRelease.Description)
+	assert.Equal(t, []interface{}{"owner", "repo", "tag_name"}, createRelease.Arguments["required"])
+	assert.Contains(t, createRelease.Arguments["properties"], "prerelease")
+}
@@ -0,0 +1,190 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Param is a query or body parameter of a GitHub operation. Path parameters
+// are taken from the path template and need not be listed.
+type Param struct {
+	Name        string
+	Type        string // JSON schema type
+	Description string
+	Required    bool
+}
+
+// Operation is a GitHub REST API operation exposed as a Wildcard function
+type Operation struct {
+	Method      string
+	Path        string // path template, e.g. /repos/{owner}/{repo}/issues
+	Description string
+	Params      []Param
+	List        bool // the response is a JSON array paged with the Link header
+}
+
+// OperationName returns the Wildcard function name for a GitHub method and path,
+// e.g. POST /repos/{owner}/{repo}/issues becomes github_post_repos_owner_repo_issues
+func OperationName(method, path string) string {
+	path = strings.TrimPrefix(path, "/")
+	path = strings.NewReplacer("{", "", "}", "", "/", "_").Replace(path)
+	return fmt.Sprintf("github_%s_%s", strings.ToLower(method), path)
+}
+
+// pathParams returns the names of the parameters in a path template
+func pathParams(path string) []string {
+	var params []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, strings.Trim(segment, "{}"))
+		}
+	}
+	return params
+}
+
+var (
+	listParams = []Param{
+		{Name: "per_page", Type: "integer", Description: "Results per page (max 100)"},
+		{Name: "page", Type: "integer", Description: "Page number, from next_page of the previous call"},
+	}
+	issueState = Param{Name: "state", Type: "string", Description: "open, closed or all"}
+)
+
+// operations are the GitHub REST operations the executor supports, covering
+// repositories, issues, pull requests and releases
+var operations = []Operation{
+	// Repositories
+	{Method: http.MethodGet, Path: "/user/repos", Description: "List repositories of the authenticated user", List: true, Params: append([]Param{
+		{Name: "visibility", Type: "string", Description: "all, public or private"},
+		{Name: "sort", Type: "string", Description: "created, updated, pushed or full_name"},
+	}, listParams...)},
+	{Method: http.MethodGet, Path: "/orgs/{org}/repos", Description: "List repositories of an organization", List: true, Params: append([]Param{
+		{Name: "type", Type: "string", Description: "all, public, private, forks, sources or member"},
+	}, listParams...)},
+	{Method: http.MethodPost, Path: "/user/repos", Description: "Create a repository for the authenticated user", Params: []Param{
+		{Name: "name", Type: "string", Required: true},
+		{Name: "description", Type: "string"},
+		{Name: "private", Type: "boolean"},
+		{Name: "auto_init", Type: "boolean", Description: "Create an initial commit with an empty README"},
+	}},
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}", Description: "Get a repository"},
+	{Method: http.MethodPatch, Path: "/repos/{owner}/{repo}", Description: "Update a repository", Params: []Param{
+		{Name: "description", Type: "string"},
+		{Name: "homepage", Type: "string"},
+		{Name: "private", Type: "boolean"},
+		{Name: "archived", Type: "boolean"},
+		{Name: "default_branch", Type: "string"},
+	}},
+
+	// Issues
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/issues", Description: "List issues in a repository, including pull requests", List: true, Params: append([]Param{
+		issueState,
+		{Name: "labels", Type: "string", Description: "Comma separated label names"},
+		{Name: "assignee", Type: "string"},
+		{Name: "since", Type: "string", Description: "Only issues updated after this ISO 8601 time"},
+	}, listParams...)},
+	{Method: http.MethodPost, Path: "/repos/{owner}/{repo}/issues", Description: "Create an issue", Params: []Param{
+		{Name: "title", Type: "string", Required: true},
+		{Name: "body", Type: "string"},
+		{Name: "assignees", Type: "array"},
+		{Name: "labels", Type: "array"},
+		{Name: "milestone", Type: "integer"},
+	}},
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/issues/{issue_number}", Description: "Get an issue"},
+	{Method: http.MethodPatch, Path: "/repos/{owner}/{repo}/issues/{issue_number}", Description: "Update an issue, e.g. to close it", Params: []Param{
+		{Name: "title", Type: "string"},
+		{Name: "body", Type: "string"},
+		{Name: "state", Type: "string", Description: "open or closed"},
+		{Name: "state_reason", Type: "string", Description: "completed, not_planned or reopened"},
+		{Name: "assignees", Type: "array"},
+		{Name: "labels", Type: "array"},
+	}},
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/issues/{issue_number}/comments", Description: "List comments on an issue or pull request", List: true, Params: listParams},
+	{Method: http.MethodPost, Path: "/repos/{owner}/{repo}/issues/{issue_number}/comments", Description: "Comment on an issue or pull request", Params: []Param{
+		{Name: "body", Type: "string", Required: true},
+	}},
+
+	// Pull requests
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/pulls", Description: "List pull requests", List: true, Params: append([]Param{
+		issueState,
+		{Name: "head", Type: "string", Description: "Filter by head user or organization and branch, e.g. user:branch"},
+		{Name: "base", Type: "string", Description: "Filter by base branch"},
+	}, listParams...)},
+	{Method: http.MethodPost, Path: "/repos/{owner}/{repo}/pulls", Description: "Create a pull request", Params: []Param{
+		{Name: "title", Type: "string", Required: true},
+		{Name: "head", Type: "string", Description: "Branch with the changes", Required: true},
+		{Name: "base", Type: "string", Description: "Branch to merge into", Required: true},
+		{Name: "body", Type: "string"},
+		{Name: "draft", Type: "boolean"},
+	}},
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/pulls/{pull_number}", Description: "Get a pull request"},
+	{Method: http.MethodPatch, Path: "/repos/{owner}/{repo}/pulls/{pull_number}", Description: "Update a pull request", Params: []Param{
+		{Name: "title", Type: "string"},
+		{Name: "body", Type: "string"},
+		{Name: "state", Type: "string", Description: "open or closed"},
+		{Name: "base", Type: "string"},
+	}},
+	{Method: http.MethodPut, Path: "/repos/{owner}/{repo}/pulls/{pull_number}/merge", Description: "Merge a pull request", Params: []Param{
+		{Name: "commit_title", Type: "string"},
+		{Name: "commit_message", Type: "string"},
+		{Name: "merge_method", Type: "string", Description: "merge, squash or rebase"},
+	}},
+
+	// Releases
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/releases", Description: "List releases", List: true, Params: listParams},
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/releases/latest", Description: "Get the latest published release"},
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/releases/tags/{tag}", Description: "Get a release by tag name"},
+	{Method: http.MethodGet, Path: "/repos/{owner}/{repo}/releases/{release_id}", Description: "Get a release"},
+	{Method: http.MethodPost, Path: "/repos/{owner}/{repo}/releases", Description: "Create a release", Params: []Param{
+		{Name: "tag_name", Type: "string", Required: true},
+		{Name: "target_commitish", Type: "string", Description: "Branch or commit to tag if the tag does not exist"},
+		{Name: "name", Type: "string"},
+		{Name: "body", Type: "string"},
+		{Name: "draft", Type: "boolean"},
+		{Name: "prerelease", Type: "boolean"},
+		{Name: "generate_release_notes", Type: "boolean"},
+	}},
+	{Method: http.MethodPatch, Path: "/repos/{owner}/{repo}/releases/{release_id}", Description: "Update a release, e.g. to publish a draft", Params: []Param{
+		{Name: "tag_name", Type: "string"},
+		{Name: "name", Type: "string"},
+		{Name: "body", Type: "string"},
+		{Name: "draft", Type: "boolean"},
+		{Name: "prerelease", Type: "boolean"},
+	}},
+	{Method: http.MethodDelete, Path: "/repos/{owner}/{repo}/releases/{release_id}", Description: "Delete a release"},
+}
+
+// Operations returns the supported GitHub operations keyed by function name
+func Operations() map[string]Operation {
+	ops := make(map[string]Operation, len(operations))
+	for _, op := range operations {
+		ops[OperationName(op.Method, op.Path)] = op
+	}
+	return ops
+}
+
+// Arguments returns the JSON schema of the operation's arguments
+func (op Operation) Arguments() map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []interface{}{}
+	for _, name := range pathParams(op.Path) {
+		properties[name] = map[string]interface{}{"type": "string"}
+		required = append(required, name)
+	}
+	for _, p := range op.Params {
+		schema := map[string]interface{}{"type": p.Type}
+		if p.Description != "" {
+			schema["description"] = p.Description
+		}
+		properties[p.Name] = schema
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
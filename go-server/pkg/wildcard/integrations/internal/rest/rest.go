@@ -0,0 +1,145 @@
+// Package rest builds and sends the requests of the executors for REST
+// integrations, which authenticate each user with a token and pass function
+// arguments as path, query or JSON body parameters.
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// TokenStore retrieves a user's token for an integration by credential name,
+// or their default token if the name is empty
+type TokenStore interface {
+	GetToken(userID, name string) (string, error)
+}
+
+// Token returns the user's token named by the credential argument of a call
+func Token(store TokenStore, userID string, args map[string]interface{}) (string, error) {
+	name, _ := args[wildcard.ArgCredential].(string)
+	return store.GetToken(userID, name)
+}
+
+// Params copies the arguments of a call without the reserved arguments that
+// are not sent to the API
+func Params(args map[string]interface{}) map[string]interface{} {
+	params := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if k != wildcard.ArgIdempotencyKey && k != wildcard.ArgCredential {
+			params[k] = v
+		}
+	}
+	return params
+}
+
+// RequireArgs checks that params has a value for each of the names. Empty
+// strings count as missing, since they cannot fill a path or identify anything.
+func RequireArgs(params map[string]interface{}, names []string) error {
+	var missing []string
+	for _, name := range names {
+		if params[name] == nil || params[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required arguments: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ArgString formats an argument for a path, query string or header. Lists are
+// joined with commas and objects are JSON encoded.
+func ArgString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = ArgString(item)
+		}
+		return strings.Join(parts, ",")
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+// Query encodes params as a query string, one value per parameter
+func Query(params map[string]interface{}) url.Values {
+	query := url.Values{}
+	for k, v := range params {
+		query.Set(k, ArgString(v))
+	}
+	return query
+}
+
+// NewRequest builds a request to target with query appended. A body that is
+// an io.Reader is sent as is, any other non-nil body JSON encoded. A non-empty
+// token is sent as a bearer token.
+func NewRequest(method, target string, query url.Values, body interface{}, token string) (*http.Request, error) {
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	encoded := false
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+		encoded = true
+	}
+
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if encoded {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// Do sends a request and reads the whole response body
+func Do(client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	return resp, body, nil
+}
@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+func TestArgString(t *testing.T) {
+	assert.Equal(t, "", ArgString(nil))
+	assert.Equal(t, "octo", ArgString("octo"))
+	assert.Equal(t, "123456789", ArgString(float64(123456789)))
+	assert.Equal(t, "100", ArgString(100))
+	assert.Equal(t, "true", ArgString(true))
+	assert.Equal(t, "bug,ui", ArgString([]interface{}{"bug", "ui"}))
+	assert.Equal(t, `{"name":"Ann"}`, ArgString(map[string]interface{}{"name": "Ann"}))
+}
+
+func TestParams(t *testing.T) {
+	args := map[string]interface{}{
+		"state":                    "open",
+		wildcard.ArgCredential:     "work",
+		wildcard.ArgIdempotencyKey: "key_123",
+	}
+	assert.Equal(t, map[string]interface{}{"state": "open"}, Params(args))
+	assert.Len(t, args, 3, "the arguments must not be modified")
+}
+
+func TestNewRequest(t *testing.T) {
+	req, err := NewRequest(http.MethodGet, "https://api.example.com/items", url.Values{"limit": {"10"}}, nil, "token_123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/items?limit=10", req.URL.String())
+	assert.Equal(t, "Bearer token_123", req.Header.Get("Authorization"))
+	assert.Nil(t, req.Body)
+
+	req, err = NewRequest(http.MethodPost, "https://api.example.com/items", nil, map[string]interface{}{"name": "Rex"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Empty(t, req.Header.Get("Authorization"))
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "Rex"}`, string(body))
+
+	req, err = NewRequest(http.MethodPost, "https://api.example.com/items", nil, strings.NewReader("name=Rex"), "")
+	require.NoError(t, err)
+	assert.Empty(t, req.Header.Get("Content-Type"), "readers are sent as is")
+	body, err = io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "name=Rex", string(body))
+}
+
+func TestRequireArgs(t *testing.T) {
+	params := map[string]interface{}{"channel": "C123", "text": nil, "user": "", "limit": 0.0}
+	assert.NoError(t, RequireArgs(params, []string{"channel", "limit"}))
+	err := RequireArgs(params, []string{"channel", "text", "thread_ts", "user"})
+	require.Error(t, err)
+	assert.Equal(t, "missing required arguments: text, thread_ts, user", err.Error())
+}
@@ -0,0 +1,89 @@
+// Package resttest provides the fake token store and API server used to test
+// the executors of REST integrations
+package resttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TokenStore holds default tokens by user ID, and named tokens by user ID and
+// name separated by a slash
+type TokenStore map[string]string
+
+// GetToken implements rest.TokenStore
+func (s TokenStore) GetToken(userID, name string) (string, error) {
+	key := userID
+	if name != "" {
+		key += "/" + name
+	}
+	token, ok := s[key]
+	if !ok {
+		return "", fmt.Errorf("no token found for user %s", userID)
+	}
+	return token, nil
+}
+
+// Request is a request received by a Server
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   string
+}
+
+// Auth returns the Authorization header of the request
+func (r Request) Auth() string {
+	return r.Header.Get("Authorization")
+}
+
+// JSON decodes the JSON body of the request
+func (r Request) JSON(t *testing.T) map[string]interface{} {
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(r.Body), &body))
+	return body
+}
+
+// Server is a fake API that records the requests it receives
+type Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewServer starts a server answering requests with handler. It is closed
+// when the test ends.
+func NewServer(t *testing.T, handler http.HandlerFunc) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+			Body:   string(body),
+		})
+		s.mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(s.Server.Close)
+	return s
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
@@ -0,0 +1,382 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Parameter is a path, query or header parameter of an operation
+type Parameter struct {
+	Name     string
+	In       string // path, query or header
+	Required bool
+}
+
+// Operation describes how an OpenAPI operation maps to an HTTP request
+type Operation struct {
+	ID          string // operationId
+	Method      string
+	Path        string
+	Description string
+	Parameters  []Parameter
+	Body        *Body
+	Arguments   map[string]interface{} // JSON schema of the arguments, from parameters and request body
+}
+
+// Body describes the request body of an operation. The arguments that are not
+// parameters are sent as its properties, or the body argument is sent as is
+// when the schema is not an object.
+type Body struct {
+	ContentType string // application/json or application/x-www-form-urlencoded
+	Required    []string
+	Raw         bool // the schema is not an object; the body argument is the whole body
+}
+
+// ArgBody is the argument carrying the request body of operations whose body is not an object
+const ArgBody = "body"
+
+// document is an OpenAPI 3 document. It is kept generic so that local $refs
+// can be resolved anywhere.
+type document map[string]interface{}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+var methods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodPatch, http.MethodHead, http.MethodOptions,
+}
+
+// parseDocument reads a JSON OpenAPI 3 document
+func parseDocument(data []byte) (document, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	version, _ := doc["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, expected 3.x", version)
+	}
+	return doc, nil
+}
+
+// ref returns the object at a local reference such as #/components/schemas/Pet
+func (d document) ref(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported reference %q, only local references are supported", ref)
+	}
+	var current interface{} = map[string]interface{}(d)
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %q", ref)
+		}
+		if current, ok = m[part]; !ok {
+			return nil, fmt.Errorf("unresolved reference %q", ref)
+		}
+	}
+	m, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("reference %q is not an object", ref)
+	}
+	return m, nil
+}
+
+// deref follows the $ref of an object, if any
+func (d document) deref(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	for i := 0; i < 10 && m != nil; i++ {
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		resolved, err := d.ref(ref)
+		if err != nil {
+			return nil
+		}
+		m = resolved
+	}
+	return m
+}
+
+// inline returns a copy of a schema with its local references resolved, up to
+// a fixed depth to stop recursive schemas
+func (d document) inline(value interface{}, depth int) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, ok := v["$ref"]; ok {
+			if depth <= 0 {
+				return map[string]interface{}{"type": "object"}
+			}
+			return d.inline(d.deref(v), depth-1)
+		}
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = d.inline(item, depth)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = d.inline(item, depth)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// operations returns the operations of the document keyed by function name,
+// which is the API name followed by the snake cased operationId, or by the
+// method and path for operations without an ID
+func (d document) operations(api string) (map[string]Operation, error) {
+	paths, _ := d["paths"].(map[string]interface{})
+	ops := make(map[string]Operation)
+	for path, item := range paths {
+		pathItem := d.deref(item)
+		for _, method := range methods {
+			raw, ok := pathItem[strings.ToLower(method)]
+			if !ok {
+				continue
+			}
+			op, err := d.operation(method, path, pathItem, d.deref(raw))
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			name := OperationName(api, op.ID, method, path)
+			if _, exists := ops[name]; exists {
+				return nil, fmt.Errorf("duplicate function name %s", name)
+			}
+			ops[name] = op
+		}
+	}
+	return ops, nil
+}
+
+// operation reads an operation, with the parameters of its path item
+func (d document) operation(method, path string, pathItem, raw map[string]interface{}) (Operation, error) {
+	op := Operation{Method: method, Path: path}
+	op.ID, _ = raw["operationId"].(string)
+	op.Description, _ = raw["summary"].(string)
+	if op.Description == "" {
+		op.Description, _ = raw["description"].(string)
+	}
+	if op.Description == "" {
+		op.Description = method + " " + path
+	}
+
+	properties := make(map[string]interface{})
+	var required []string
+
+	// Operation parameters override path item parameters with the same name and location
+	byKey := make(map[string]int)
+	for _, list := range []interface{}{pathItem["parameters"], raw["parameters"]} {
+		items, _ := list.([]interface{})
+		for _, item := range items {
+			param := d.deref(item)
+			if param == nil {
+				return op, fmt.Errorf("unresolved parameter")
+			}
+			p := Parameter{}
+			p.Name, _ = param["name"].(string)
+			p.In, _ = param["in"].(string)
+			p.Required, _ = param["required"].(bool)
+			if p.In != "path" && p.In != "query" && p.In != "header" {
+				continue
+			}
+			if p.In == "path" {
+				p.Required = true
+			}
+			schema := map[string]interface{}{"type": "string"}
+			if s, ok := d.inline(param["schema"], 3).(map[string]interface{}); ok {
+				schema = s
+			}
+			if description, ok := param["description"].(string); ok && description != "" {
+				schema["description"] = description
+			}
+			properties[p.Name] = schema
+
+			key := p.In + ":" + p.Name
+			if i, exists := byKey[key]; exists {
+				op.Parameters[i] = p
+				continue
+			}
+			byKey[key] = len(op.Parameters)
+			op.Parameters = append(op.Parameters, p)
+		}
+	}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		if _, exists := byKey["path:"+match[1]]; !exists {
+			op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true})
+			properties[match[1]] = map[string]interface{}{"type": "string"}
+		}
+	}
+	for _, p := range op.Parameters {
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+
+	if requestBody := d.deref(raw["requestBody"]); requestBody != nil {
+		content, _ := requestBody["content"].(map[string]interface{})
+		for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded"} {
+			media, ok := content[contentType].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op.Body = &Body{ContentType: contentType}
+			schema, _ := d.inline(media["schema"], 3).(map[string]interface{})
+			bodyProperties, ok := schema["properties"].(map[string]interface{})
+			if !ok {
+				op.Body.Raw = true
+				if schema == nil {
+					schema = map[string]interface{}{}
+				}
+				properties[ArgBody] = schema
+				if bodyRequired, _ := requestBody["required"].(bool); bodyRequired {
+					op.Body.Required = []string{ArgBody}
+				}
+			} else {
+				for name, property := range bodyProperties {
+					properties[name] = property
+				}
+				items, _ := schema["required"].([]interface{})
+				for _, item := range items {
+					if name, ok := item.(string); ok {
+						op.Body.Required = append(op.Body.Required, name)
+					}
+				}
+			}
+			required = append(required, op.Body.Required...)
+			break
+		}
+		if op.Body == nil && len(content) > 0 {
+			return op, fmt.Errorf("unsupported request body content type")
+		}
+	}
+
+	op.Arguments = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		op.Arguments["required"] = required
+	}
+	return op, nil
+}
+
+// OperationName returns the Wildcard function name of an operation, e.g. the
+// operation showPetById of the petstore API becomes petstore_show_pet_by_id,
+// and GET /pets/{petId} without an operationId petstore_get_pets_pet_id
+func OperationName(api, operationID, method, path string) string {
+	if operationID != "" {
+		return api + "_" + snakeCase(operationID)
+	}
+	path = strings.NewReplacer("{", "", "}", "").Replace(strings.TrimPrefix(path, "/"))
+	return api + "_" + strings.ToLower(method) + "_" + snakeCase(path)
+}
+
+// snakeCase converts an identifier such as listPets or get-HTTPStatus to
+// lower snake case
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteRune('_')
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	parts := strings.FieldsFunc(b.String(), func(r rune) bool { return r == '_' })
+	return strings.Join(parts, "_")
+}
+
+// securityScheme returns the auth scheme of a security scheme in the document
+func (d document) securityScheme(name string) (AuthScheme, error) {
+	scheme, err := d.ref("#/components/securitySchemes/" + name)
+	if err != nil {
+		return AuthScheme{}, fmt.Errorf("unknown security scheme %q", name)
+	}
+	schemeType, _ := scheme["type"].(string)
+	switch schemeType {
+	case "http":
+		httpScheme, _ := scheme["scheme"].(string)
+		switch strings.ToLower(httpScheme) {
+		case "bearer":
+			return AuthScheme{Type: AuthBearer}, nil
+		case "basic":
+			return AuthScheme{Type: AuthBasic}, nil
+		}
+		return AuthScheme{}, fmt.Errorf("unsupported HTTP auth scheme %q", httpScheme)
+	case "apiKey":
+		in, _ := scheme["in"].(string)
+		paramName, _ := scheme["name"].(string)
+		switch in {
+		case "header":
+			return AuthScheme{Type: AuthHeader, Name: paramName}, nil
+		case "query":
+			return AuthScheme{Type: AuthQuery, Name: paramName}, nil
+		}
+		return AuthScheme{}, fmt.Errorf("unsupported API key location %q", in)
+	case "oauth2", "openIdConnect":
+		// The stored credential is the access token
+		return AuthScheme{Type: AuthBearer}, nil
+	}
+	return AuthScheme{}, fmt.Errorf("unsupported security scheme type %q", schemeType)
+}
+
+// defaultSecurityScheme returns the first scheme of the document's top-level
+// security requirement, if any
+func (d document) defaultSecurityScheme() string {
+	requirements, _ := d["security"].([]interface{})
+	for _, requirement := range requirements {
+		schemes, _ := requirement.(map[string]interface{})
+		names := make([]string, 0, len(schemes))
+		for name := range schemes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			return names[0]
+		}
+	}
+	return ""
+}
+
+// serverURL returns the URL of the document's first server
+func (d document) serverURL() string {
+	servers, _ := d["servers"].([]interface{})
+	if len(servers) == 0 {
+		return ""
+	}
+	server, _ := servers[0].(map[string]interface{})
+	url, _ := server["url"].(string)
+	return url
+}
+
+// description returns the title and description of the document
+func (d document) description() string {
+	info, _ := d["info"].(map[string]interface{})
+	title, _ := info["title"].(string)
+	description, _ := info["description"].(string)
+	if description == "" {
+		return title
+	}
+	if title == "" {
+		return description
+	}
+	return title + ": " + description
+}
@@ -0,0 +1,385 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/internal/rest"
+)
+
+// Auth scheme types
+const (
+	AuthNone   = "none"   // no credentials are sent
+	AuthBearer = "bearer" // Authorization: Bearer <credential>
+	AuthBasic  = "basic"  // HTTP basic auth, with a credential of the form user:password or user
+	AuthHeader = "header" // the credential in the header Name
+	AuthQuery  = "query"  // the credential in the query parameter Name
+)
+
+// AuthScheme describes how a user's credential is sent. Either Scheme names a
+// security scheme of the document, or Type and Name describe it directly.
+// When neither is set the document's default security requirement is used.
+type AuthScheme struct {
+	Scheme string `json:"scheme,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Config configures an executor for one API
+type Config struct {
+	API         string     `json:"api"`                   // API name the executor is registered under
+	Document    string     `json:"document"`              // path of the JSON OpenAPI 3 document
+	BaseURL     string     `json:"base_url,omitempty"`    // overrides the document's first server
+	Description string     `json:"description,omitempty"` // overrides the document's info
+	Auth        AuthScheme `json:"auth"`
+}
+
+// TokenStore retrieves users' credentials for the API by credential name. How
+// a credential is sent is set by the config's AuthScheme.
+type TokenStore = rest.TokenStore
+
+// Executor executes the operations of an OpenAPI 3 document
+type Executor struct {
+	api         string
+	baseURL     string
+	description string
+	auth        AuthScheme
+	operations  map[string]Operation
+	tokenStore  TokenStore
+	httpClient  *http.Client
+}
+
+// LoadConfigs reads a JSON list of configs. Document paths are relative to
+// the directory of the file.
+func LoadConfigs(path string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i := range configs {
+		if configs[i].Document != "" && !filepath.IsAbs(configs[i].Document) {
+			configs[i].Document = filepath.Join(filepath.Dir(path), configs[i].Document)
+		}
+	}
+	return configs, nil
+}
+
+// New creates an executor from a config, reading its document from disk
+func New(cfg Config, tokenStore TokenStore) (*Executor, error) {
+	data, err := os.ReadFile(cfg.Document)
+	if err != nil {
+		return nil, err
+	}
+	return NewExecutor(data, cfg, tokenStore)
+}
+
+// NewExecutor creates an executor for a JSON OpenAPI 3 document. The
+// Document field of the config is ignored.
+func NewExecutor(documentJSON []byte, cfg Config, tokenStore TokenStore) (*Executor, error) {
+	if cfg.API == "" {
+		return nil, fmt.Errorf("API name cannot be empty")
+	}
+	doc, err := parseDocument(documentJSON)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := doc.operations(cfg.API)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.API, err)
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = doc.serverURL()
+	}
+	if u, err := url.Parse(baseURL); err != nil || !u.IsAbs() {
+		return nil, fmt.Errorf("%s: an absolute base URL is required, got %q", cfg.API, baseURL)
+	}
+
+	auth := cfg.Auth
+	if auth.Scheme == "" && auth.Type == "" {
+		auth.Scheme = doc.defaultSecurityScheme()
+	}
+	if auth.Scheme != "" {
+		if auth, err = doc.securityScheme(auth.Scheme); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.API, err)
+		}
+	}
+	switch auth.Type {
+	case "":
+		auth.Type = AuthNone
+	case AuthNone, AuthBearer, AuthBasic:
+	case AuthHeader, AuthQuery:
+		if auth.Name == "" {
+			return nil, fmt.Errorf("%s: %s auth requires a name", cfg.API, auth.Type)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown auth type %q", cfg.API, auth.Type)
+	}
+
+	description := cfg.Description
+	if description == "" {
+		description = doc.description()
+	}
+
+	return &Executor{
+		api:         cfg.API,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		description: description,
+		auth:        auth,
+		operations:  ops,
+		tokenStore:  tokenStore,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// API returns the API name of the executor
+func (e *Executor) API() string {
+	return e.api
+}
+
+// Register registers the executor with a Wildcard client under its API name
+func (e *Executor) Register(client *wildcard.Client) {
+	client.RegisterExecutor(e.api, e)
+}
+
+// SetHTTPClient sets the HTTP client used for requests to the API's base URL,
+// so that documents can be exercised against a recorder or local server
+func (e *Executor) SetHTTPClient(client *http.Client) {
+	e.httpClient = client
+}
+
+// Operations returns the operations of the executor keyed by function name
+func (e *Executor) Operations() map[string]Operation {
+	return e.operations
+}
+
+// Description returns the config's description, or the title and description
+// of the document's info, which the classifier matches messages against
+func (e *Executor) Description() string {
+	return e.description
+}
+
+// RequiresCredentials reports whether users need a credential for the API,
+// which is not the case for APIs without authentication
+func (e *Executor) RequiresCredentials() bool {
+	return e.auth.Type != AuthNone
+}
+
+// CredentialQueryParam returns the query parameter that carries the
+// credential, or "" if the API takes it elsewhere
+func (e *Executor) CredentialQueryParam() string {
+	if e.auth.Type != AuthQuery {
+		return ""
+	}
+	return e.auth.Name
+}
+
+// Catalog lists the operations of the document. GET and HEAD operations are
+// read-only.
+func (e *Executor) Catalog() []wildcard.FunctionInfo {
+	catalog := make([]wildcard.FunctionInfo, 0, len(e.operations))
+	for name, op := range e.operations {
+		catalog = append(catalog, wildcard.FunctionInfo{
+			Name:        name,
+			Description: op.Description,
+			Arguments:   op.Arguments,
+			ReadOnly:    op.Method == http.MethodGet || op.Method == http.MethodHead,
+		})
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+	return catalog
+}
+
+// APIError is an error response from the API
+type APIError struct {
+	API        string
+	StatusCode int
+	Message    string
+	Body       interface{}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.API, e.StatusCode, e.Message)
+}
+
+// Details returns the structured details of the error
+func (e *APIError) Details() map[string]interface{} {
+	details := map[string]interface{}{
+		"message": e.Message,
+		"status":  e.StatusCode,
+	}
+	if e.Body != nil {
+		details["body"] = e.Body
+	}
+	return details
+}
+
+// ExecuteFunction executes an operation by function name. Arguments named
+// after path, query and header parameters are sent as such; the remaining
+// arguments are the properties of the request body.
+func (e *Executor) ExecuteFunction(userID string, name string, args map[string]interface{}) (interface{}, error) {
+	op, exists := e.operations[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown function: %s", name)
+	}
+
+	var credential string
+	if e.auth.Type != AuthNone {
+		token, err := rest.Token(e.tokenStore, userID, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s credentials for user %s: %v", e.api, userID, err)
+		}
+		credential = token
+	}
+
+	params := rest.Params(args)
+	required, _ := op.Arguments["required"].([]string)
+	if err := rest.RequireArgs(params, required); err != nil {
+		return nil, err
+	}
+
+	req, err := e.newRequest(op, params, credential)
+	if err != nil {
+		return nil, err
+	}
+	resp, body, err := rest.Do(e.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %v", e.api, err)
+	}
+
+	var result interface{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &result); err != nil {
+			result = string(body)
+		}
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &APIError{API: e.api, StatusCode: resp.StatusCode, Message: errorMessage(resp.StatusCode, result), Body: result}
+	}
+	if result == nil {
+		return map[string]interface{}{"status": resp.StatusCode}, nil
+	}
+	return result, nil
+}
+
+// newRequest builds the request of an operation. It consumes params.
+func (e *Executor) newRequest(op Operation, params map[string]interface{}, credential string) (*http.Request, error) {
+	path := op.Path
+	query := url.Values{}
+	header := http.Header{}
+	for _, p := range op.Parameters {
+		value, ok := params[p.Name]
+		delete(params, p.Name)
+		if !ok || value == nil {
+			continue
+		}
+		switch p.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(rest.ArgString(value)))
+		case "query":
+			for _, v := range argValues(value) {
+				query.Add(p.Name, v)
+			}
+		case "header":
+			header.Set(p.Name, rest.ArgString(value))
+		}
+	}
+
+	var body interface{}
+	if op.Body != nil {
+		var payload interface{} = params
+		if op.Body.Raw {
+			payload = params[ArgBody]
+		}
+		switch {
+		case payload == nil:
+		case op.Body.ContentType == "application/x-www-form-urlencoded":
+			form := url.Values{}
+			if fields, ok := payload.(map[string]interface{}); ok {
+				for k, v := range fields {
+					for _, value := range argValues(v) {
+						form.Add(k, value)
+					}
+				}
+			}
+			body = strings.NewReader(form.Encode())
+			header.Set("Content-Type", op.Body.ContentType)
+		default:
+			body = payload
+			header.Set("Content-Type", op.Body.ContentType)
+		}
+	}
+
+	var bearer string
+	switch e.auth.Type {
+	case AuthBearer:
+		bearer = credential
+	case AuthHeader:
+		header.Set(e.auth.Name, credential)
+	case AuthQuery:
+		query.Set(e.auth.Name, credential)
+	}
+
+	req, err := rest.NewRequest(op.Method, e.baseURL+path, query, body, bearer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s request: %v", e.api, err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if e.auth.Type == AuthBasic {
+		username, password, _ := strings.Cut(credential, ":")
+		req.SetBasicAuth(username, password)
+	}
+	return req, nil
+}
+
+// errorMessage extracts the message of an error response
+func errorMessage(status int, body interface{}) string {
+	switch v := body.(type) {
+	case map[string]interface{}:
+		for _, key := range []string{"message", "error_description", "detail", "title", "error"} {
+			if message, ok := v[key].(string); ok && message != "" {
+				return message
+			}
+			if nested, ok := v[key].(map[string]interface{}); ok {
+				if message, ok := nested["message"].(string); ok && message != "" {
+					return message
+				}
+			}
+		}
+	case string:
+		if v = strings.TrimSpace(v); v != "" && len(v) <= 200 {
+			return v
+		}
+	}
+	return http.StatusText(status)
+}
+
+// argValues formats an argument as query or form values. Lists are exploded
+// into one value per item.
+func argValues(value interface{}) []string {
+	if items, ok := value.([]interface{}); ok {
+		values := make([]string, len(items))
+		for i, item := range items {
+			values[i] = rest.ArgString(item)
+		}
+		return values
+	}
+	return []string{rest.ArgString(value)}
+}
@@ -0,0 +1,224 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/internal/rest/resttest"
+)
+
+// newPetstore creates an executor for testdata/petstore.json against a local
+// server answering every request with status and body
+func newPetstore(t *testing.T, cfg Config, status int, body string) (*Executor, *resttest.Server) {
+	server := resttest.NewServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+
+	document, err := os.ReadFile(filepath.Join("testdata", "petstore.json"))
+	require.NoError(t, err)
+	cfg.API = "petstore"
+	cfg.BaseURL = server.URL + "/v1"
+	executor, err := NewExecutor(document, cfg, resttest.TokenStore{"user123": "secret_123"})
+	require.NoError(t, err)
+	return executor, server
+}
+
+func TestOperations(t *testing.T) {
+	executor, _ := newPetstore(t, Config{}, http.StatusOK, "")
+	assert.Equal(t, "Petstore: Manage the pets of the store", executor.Description())
+
+	var names []string
+	byName := make(map[string]wildcard.FunctionInfo)
+	for _, fn := range executor.Catalog() {
+		names = append(names, fn.Name)
+		byName[fn.Name] = fn
+	}
+	assert.Equal(t, []string{
+		"petstore_create_adoption",
+		"petstore_create_pet",
+		"petstore_delete_pets_pet_id",
+		"petstore_list_pets",
+		"petstore_replace_pet_notes",
+		"petstore_show_pet_by_id",
+	}, names)
+
+	listPets := byName["petstore_list_pets"]
+	assert.True(t, listPets.ReadOnly)
+	assert.Equal(t, "List all pets", listPets.Description)
+	assert.Equal(t, map[string]interface{}{"type": "integer", "description": "How many items to return"},
+		listPets.Arguments["properties"].(map[string]interface{})["limit"])
+	assert.Nil(t, listPets.Arguments["required"])
+
+	createPet := byName["petstore_create_pet"]
+	assert.False(t, createPet.ReadOnly)
+	assert.Equal(t, []string{"name"}, createPet.Arguments["required"])
+	properties := createPet.Arguments["properties"].(map[string]interface{})
+	assert.Contains(t, properties, "X-Request-ID")
+	assert.Equal(t, map[string]interface{}{"type": "object", "properties": map[string]interface{}{"name": map[string]interface{}{"type": "string"}}},
+		properties["owner"], "references are inlined")
+
+	assert.Equal(t, []string{"petId"}, byName["petstore_show_pet_by_id"].Arguments["required"])
+	assert.Equal(t, "DELETE /pets/{petId}", byName["petstore_delete_pets_pet_id"].Description)
+}
+
+func TestExecuteFunction(t *testing.T) {
+	executor, server := newPetstore(t, Config{}, http.StatusOK, `{"id": "pet_1", "name": "Rex"}`)
+
+	result, err := executor.ExecuteFunction("user123", "petstore_create_pet", map[string]interface{}{
+		"name":                     "Rex",
+		"owner":                    map[string]interface{}{"name": "Ann"},
+		"X-Request-ID":             "req_1",
+		wildcard.ArgIdempotencyKey: "key_1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "pet_1", "name": "Rex"}, result)
+	req := server.Requests()[0]
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "/v1/pets", req.Path)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"name": "Rex", "owner": {"name": "Ann"}}`, req.Body)
+	assert.Equal(t, "req_1", req.Header.Get("X-Request-ID"))
+	assert.Equal(t, "secret_123", req.Header.Get("X-API-Key"), "the document's default security scheme is used")
+
+	_, err = executor.ExecuteFunction("user123", "petstore_list_pets", map[string]interface{}{
+		"limit": float64(10),
+		"tags":  []interface{}{"dog", "cat"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "limit=10&tags=dog&tags=cat", server.Requests()[1].Query)
+
+	_, err = executor.ExecuteFunction("user123", "petstore_show_pet_by_id", map[string]interface{}{"petId": "pet 1"})
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/pets/pet 1", server.Requests()[2].Path)
+
+	_, err = executor.ExecuteFunction("user123", "petstore_replace_pet_notes", map[string]interface{}{
+		"petId": "pet_1",
+		"body":  []interface{}{"friendly"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `["friendly"]`, server.Requests()[3].Body)
+
+	_, err = executor.ExecuteFunction("user123", "petstore_create_adoption", map[string]interface{}{
+		"pet_id": "pet_1",
+		"owners": []interface{}{"Ann", "Bob"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", server.Requests()[4].Header.Get("Content-Type"))
+	assert.Equal(t, "owners=Ann&owners=Bob&pet_id=pet_1", server.Requests()[4].Body)
+
+	_, err = executor.ExecuteFunction("user123", "petstore_create_pet", map[string]interface{}{"tag": "dog"})
+	require.Error(t, err)
+	assert.Equal(t, "missing required arguments: name", err.Error())
+
+	_, err = executor.ExecuteFunction("other", "petstore_list_pets", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get petstore credentials")
+	assert.Len(t, server.Requests(), 5, "invalid calls are not sent")
+}
+
+func TestAuthSchemes(t *testing.T) {
+	tests := []struct {
+		name  string
+		auth  AuthScheme
+		check func(t *testing.T, req resttest.Request)
+	}{
+		{
+			name: "document scheme",
+			auth: AuthScheme{Scheme: "bearer"},
+			check: func(t *testing.T, req resttest.Request) {
+				assert.Equal(t, "Bearer secret_123", req.Header.Get("Authorization"))
+			},
+		},
+		{
+			name: "query",
+			auth: AuthScheme{Type: AuthQuery, Name: "api_key"},
+			check: func(t *testing.T, req resttest.Request) {
+				assert.Equal(t, "api_key=secret_123", req.Query)
+			},
+		},
+		{
+			name: "basic",
+			auth: AuthScheme{Type: AuthBasic},
+			check: func(t *testing.T, req resttest.Request) {
+				assert.Equal(t, "Basic c2VjcmV0XzEyMzo=", req.Header.Get("Authorization"))
+			},
+		},
+		{
+			name: "none",
+			auth: AuthScheme{Type: AuthNone},
+			check: func(t *testing.T, req resttest.Request) {
+				assert.Empty(t, req.Header.Get("Authorization"))
+				assert.Empty(t, req.Header.Get("X-API-Key"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor, server := newPetstore(t, Config{Auth: tt.auth}, http.StatusNoContent, "")
+			result, err := executor.ExecuteFunction("user123", "petstore_delete_pets_pet_id", map[string]interface{}{"petId": "pet_1"})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"status": http.StatusNoContent}, result)
+			tt.check(t, server.Requests()[0])
+			assert.Equal(t, tt.auth.Type != AuthNone, executor.RequiresCredentials())
+			if tt.auth.Type == AuthQuery {
+				assert.Equal(t, tt.auth.Name, executor.CredentialQueryParam())
+			} else {
+				assert.Empty(t, executor.CredentialQueryParam())
+			}
+		})
+	}
+
+	document, err := os.ReadFile(filepath.Join("testdata", "petstore.json"))
+	require.NoError(t, err)
+	_, err = NewExecutor(document, Config{API: "petstore", Auth: AuthScheme{Scheme: "oauth"}}, nil)
+	assert.Error(t, err, "unknown security schemes are rejected")
+	_, err = NewExecutor(document, Config{API: "petstore", BaseURL: "/v1"}, nil)
+	assert.Error(t, err, "relative base URLs are rejected")
+}
+
+func TestExecuteFunctionError(t *testing.T) {
+	executor, _ := newPetstore(t, Config{}, http.StatusNotFound, `{"error": {"message": "No such pet"}}`)
+
+	_, err := executor.ExecuteFunction("user123", "petstore_show_pet_by_id", map[string]interface{}{"petId": "pet_2"})
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "petstore API error (status 404): No such pet", err.Error())
+	assert.Equal(t, http.StatusNotFound, apiErr.Details()["status"])
+}
+
+func TestLoadConfigs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "integrations.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"api": "petstore", "document": "petstore.json", "auth": {"type": "bearer"}}
+	]`), 0o600))
+
+	configs, err := LoadConfigs(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Config{{
+		API:      "petstore",
+		Document: filepath.Join(dir, "petstore.json"),
+		Auth:     AuthScheme{Type: AuthBearer},
+	}}, configs)
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"listPets":          "list_pets",
+		"showPetById":       "show_pet_by_id",
+		"get-HTTPStatus":    "get_http_status",
+		"v2UserRepos":       "v2_user_repos",
+		"pets/petId/notes_": "pets_pet_id_notes",
+	} {
+		assert.Equal(t, want, snakeCase(in), in)
+	}
+}
@@ -0,0 +1,200 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Slack Web API
+const DefaultBaseURL = "https://slack.com/api"
+
+// MaxListItems is the limit sent with list methods that ask for more items
+// than Slack recommends per page, or none
+var MaxListItems = 200
+
+// Executor handles Slack Web API operations
+type Executor struct {
+	tokenStore TokenStore
+	baseURL    string
+	httpClient *http.Client
+}
+
+// TokenStore retrieves the bot tokens of users' Slack workspaces by credential name
+type TokenStore = rest.TokenStore
+
+// NewExecutor creates a new Slack executor for the Web API at baseURL, or the
+// public Slack API if baseURL is empty
+func NewExecutor(tokenStore TokenStore, baseURL string) *Executor {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Executor{
+		tokenStore: tokenStore,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetHTTPClient sets the HTTP client used for Web API calls, for instance to
+// send them to a local fake of Slack
+func (e *Executor) SetHTTPClient(client *http.Client) {
+	e.httpClient = client
+}
+
+// Description lists what the classifier can route to Slack
+func (e *Executor) Description() string {
+	return "Slack workspace: post messages to channels or users, list channels and look up users"
+}
+
+// Catalog lists the supported Slack methods
+func (e *Executor) Catalog() []wildcard.FunctionInfo {
+	catalog := make([]wildcard.FunctionInfo, 0, len(Methods))
+	for name, m := range Methods {
+		catalog = append(catalog, wildcard.FunctionInfo{
+			Name:        name,
+			Description: m.Description,
+			Arguments:   m.Arguments(),
+			ReadOnly:    m.ReadOnly,
+		})
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+	return catalog
+}
+
+// ListPage is a single page of list results. NextCursor is passed back as
+// cursor to fetch the next one.
+type ListPage struct {
+	Object     string        `json:"object"`
+	Data       []interface{} `json:"data"`
+	HasMore    bool          `json:"has_more"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// APIError is an error response from the Slack Web API. Slack answers most
+// errors with HTTP 200 and "ok": false.
+type APIError struct {
+	Method     string
+	StatusCode int
+	Code       string // e.g. channel_not_found or missing_scope
+	Needed     string // the scope missing for missing_scope
+	RetryAfter int    // seconds to wait when rate limited
+}
+
+func (e *APIError) Error() string {
+	if e.Needed != "" {
+		return fmt.Sprintf("Slack API error in %s: %s (needs %s)", e.Method, e.Code, e.Needed)
+	}
+	return fmt.Sprintf("Slack API error in %s: %s", e.Method, e.Code)
+}
+
+// Details returns the structured details of the error
+func (e *APIError) Details() map[string]interface{} {
+	details := map[string]interface{}{
+		"method": e.Method,
+		"code":   e.Code,
+		"status": e.StatusCode,
+	}
+	if e.Needed != "" {
+		details["needed"] = e.Needed
+	}
+	if e.RetryAfter > 0 {
+		details["retry_after"] = e.RetryAfter
+	}
+	return details
+}
+
+// ExecuteFunction executes a Slack function by name with given arguments. Read
+// methods are sent as GET with the arguments in the query string, the others
+// as POST with a JSON body.
+func (e *Executor) ExecuteFunction(userID string, name string, args map[string]interface{}) (interface{}, error) {
+	m, exists := Methods[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown function: %s", name)
+	}
+
+	token, err := rest.Token(e.tokenStore, userID, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Slack token for user %s: %v", userID, err)
+	}
+
+	params := rest.Params(args)
+	var required []string
+	for _, p := range m.Params {
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	if err := rest.RequireArgs(params, required); err != nil {
+		return nil, err
+	}
+	if m.ListKey != "" {
+		if limit, err := strconv.Atoi(rest.ArgString(params["limit"])); err != nil || limit < 1 || limit > MaxListItems {
+			params["limit"] = MaxListItems
+		}
+	}
+
+	req, err := e.newRequest(m, params, token)
+	if err != nil {
+		return nil, err
+	}
+	resp, body, err := rest.Do(e.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("Slack request failed: %v", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, &APIError{Method: m.Method, StatusCode: resp.StatusCode, Code: "ratelimited", RetryAfter: retryAfter}
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		if resp.StatusCode >= 400 {
+			return nil, &APIError{Method: m.Method, StatusCode: resp.StatusCode, Code: http.StatusText(resp.StatusCode)}
+		}
+		return nil, fmt.Errorf("failed to decode Slack response: %v", err)
+	}
+	if ok, _ := result["ok"].(bool); !ok {
+		code, _ := result["error"].(string)
+		needed, _ := result["needed"].(string)
+		return nil, &APIError{Method: m.Method, StatusCode: resp.StatusCode, Code: code, Needed: needed}
+	}
+
+	if m.ListKey == "" {
+		return result, nil
+	}
+	items, _ := result[m.ListKey].([]interface{})
+	page := &ListPage{Object: "list", Data: items}
+	if page.Data == nil {
+		page.Data = []interface{}{}
+	}
+	if metadata, ok := result["response_metadata"].(map[string]interface{}); ok {
+		page.NextCursor, _ = metadata["next_cursor"].(string)
+	}
+	page.HasMore = page.NextCursor != ""
+	return page, nil
+}
+
+// newRequest builds the request for a Web API method: a GET with the params
+// in the query string for read methods, and a POST with a JSON body otherwise
+func (e *Executor) newRequest(m Method, params map[string]interface{}, token string) (*http.Request, error) {
+	target := e.baseURL + "/" + m.Method
+	if m.ReadOnly {
+		return rest.NewRequest(http.MethodGet, target, rest.Query(params), nil, token)
+	}
+
+	req, err := rest.NewRequest(http.MethodPost, target, nil, params, token)
+	if err != nil {
+		return nil, err
+	}
+	// Slack warns about JSON bodies without a charset
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return req, nil
+}
@@ -0,0 +1,120 @@
+package slack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard/integrations/internal/rest/resttest"
+)
+
+// newFakeSlack answers each Web API method with the given response, and
+// conversations.history with a rate limit
+func newFakeSlack(t *testing.T, responses map[string]string) *resttest.Server {
+	return resttest.NewServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/conversations.history" {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, responses[r.URL.Path])
+	})
+}
+
+func TestExecuteFunction(t *testing.T) {
+	server := newFakeSlack(t, map[string]string{
+		"/chat.postMessage":   `{"ok": true, "channel": "C123", "ts": "1700000000.000100"}`,
+		"/conversations.list": `{"ok": true, "channels": [{"id": "C123", "name": "finance"}], "response_metadata": {"next_cursor": "dGVhbTpDMDYx"}}`,
+		"/users.list":         `{"ok": true, "members": [], "response_metadata": {"next_cursor": ""}}`,
+	})
+	executor := NewExecutor(resttest.TokenStore{"user123": "xoxb-123"}, server.URL)
+
+	result, err := executor.ExecuteFunction("user123", "slack_chat_post_message", map[string]interface{}{
+		"channel": "#finance",
+		"text":    "Refunded $20.00 to cus_123",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1700000000.000100", result.(map[string]interface{})["ts"])
+	requests := server.Requests()
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Equal(t, "/chat.postMessage", requests[0].Path)
+	assert.Equal(t, "Bearer xoxb-123", requests[0].Auth())
+	assert.Equal(t, "application/json; charset=utf-8", requests[0].Header.Get("Content-Type"))
+	assert.Equal(t, map[string]interface{}{"channel": "#finance", "text": "Refunded $20.00 to cus_123"}, requests[0].JSON(t))
+
+	result, err = executor.ExecuteFunction("user123", "slack_conversations_list", map[string]interface{}{
+		"types": "public_channel",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &ListPage{
+		Object:     "list",
+		Data:       []interface{}{map[string]interface{}{"id": "C123", "name": "finance"}},
+		HasMore:    true,
+		NextCursor: "dGVhbTpDMDYx",
+	}, result)
+	requests = server.Requests()
+	assert.Equal(t, http.MethodGet, requests[1].Method)
+	assert.Equal(t, "limit=200&types=public_channel", requests[1].Query)
+
+	result, err = executor.ExecuteFunction("user123", "slack_users_list", map[string]interface{}{"limit": float64(50)})
+	require.NoError(t, err)
+	assert.Equal(t, &ListPage{Object: "list", Data: []interface{}{}}, result)
+	assert.Equal(t, "limit=50", server.Requests()[2].Query)
+}
+
+func TestExecuteFunctionErrors(t *testing.T) {
+	server := newFakeSlack(t, map[string]string{
+		"/chat.postMessage": `{"ok": false, "error": "not_in_channel"}`,
+		"/users.info":       `{"ok": false, "error": "missing_scope", "needed": "users:read"}`,
+	})
+	executor := NewExecutor(resttest.TokenStore{"user123": "xoxb-123"}, server.URL)
+
+	_, err := executor.ExecuteFunction("user123", "slack_chat_post_message", map[string]interface{}{
+		"channel": "C123", "text": "hello",
+	})
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "Slack API error in chat.postMessage: not_in_channel", err.Error())
+
+	_, err = executor.ExecuteFunction("user123", "slack_users_info", map[string]interface{}{"user": "U123"})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, map[string]interface{}{
+		"method": "users.info",
+		"code":   "missing_scope",
+		"status": http.StatusOK,
+		"needed": "users:read",
+	}, apiErr.Details())
+
+	_, err = executor.ExecuteFunction("user123", "slack_conversations_history", map[string]interface{}{"channel": "C123"})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "ratelimited", apiErr.Code)
+	assert.Equal(t, 30, apiErr.RetryAfter)
+
+	_, err = executor.ExecuteFunction("user123", "slack_chat_post_message", map[string]interface{}{"channel": "C123"})
+	require.Error(t, err)
+	assert.Equal(t, "missing required arguments: text", err.Error())
+
+	_, err = executor.ExecuteFunction("other", "slack_users_list", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get Slack token")
+	assert.Len(t, server.Requests(), 3, "invalid calls are not sent")
+}
+
+func TestCatalog(t *testing.T) {
+	catalog := NewExecutor(resttest.TokenStore{}, "").Catalog()
+	require.Len(t, catalog, len(Methods))
+	assert.Equal(t, "slack_chat_delete", catalog[0].Name)
+
+	for _, fn := range catalog {
+		if fn.Name == "slack_chat_post_message" {
+			assert.False(t, fn.ReadOnly)
+			assert.Equal(t, []interface{}{"channel", "text"}, fn.Arguments["required"])
+		}
+		if fn.Name == "slack_users_lookup_by_email" {
+			assert.True(t, fn.ReadOnly)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package slack
+
+// Param is an argument of a Slack Web API method
+type Param struct {
+	Name        string
+	Type        string // JSON schema type
+	Description string
+	Required    bool
+}
+
+// Method is a Slack Web API method exposed as a Wildcard function
+type Method struct {
+	Method      string // Web API method, e.g. chat.postMessage
+	Description string
+	Params      []Param
+	ReadOnly    bool   // read methods are sent as GET with a query string, others as POST with a JSON body
+	ListKey     string // the field holding the items of a cursor-paged list
+}
+
+var listParams = []Param{
+	{Name: "limit", Type: "integer", Description: "Maximum number of items to return (max 200)"},
+	{Name: "cursor", Type: "string", Description: "next_cursor of the previous call"},
+}
+
+// Methods are the Slack Web API methods the executor supports, keyed by
+// function name
+var Methods = map[string]Method{
+	// Messages
+	"slack_chat_post_message": {Method: "chat.postMessage", Description: "Post a message to a channel, e.g. #finance, or a user ID for a direct message", Params: []Param{
+		{Name: "channel", Type: "string", Description: "Channel ID or name", Required: true},
+		{Name: "text", Type: "string", Description: "Message text, formatted with Slack mrkdwn", Required: true},
+		{Name: "thread_ts", Type: "string", Description: "ts of the parent message to reply in its thread"},
+		{Name: "blocks", Type: "array", Description: "Block Kit blocks"},
+		{Name: "unfurl_links", Type: "boolean"},
+	}},
+	"slack_chat_update": {Method: "chat.update", Description: "Edit a message posted by the bot", Params: []Param{
+		{Name: "channel", Type: "string", Description: "Channel ID", Required: true},
+		{Name: "ts", Type: "string", Description: "ts of the message", Required: true},
+		{Name: "text", Type: "string", Required: true},
+	}},
+	"slack_chat_delete": {Method: "chat.delete", Description: "Delete a message posted by the bot", Params: []Param{
+		{Name: "channel", Type: "string", Description: "Channel ID", Required: true},
+		{Name: "ts", Type: "string", Description: "ts of the message", Required: true},
+	}},
+
+	// Channels
+	"slack_conversations_list": {Method: "conversations.list", Description: "List channels in the workspace", ReadOnly: true, ListKey: "channels", Params: append([]Param{
+		{Name: "types", Type: "string", Description: "Comma separated public_channel, private_channel, mpim or im"},
+		{Name: "exclude_archived", Type: "boolean"},
+	}, listParams...)},
+	"slack_conversations_info": {Method: "conversations.info", Description: "Get a channel", ReadOnly: true, Params: []Param{
+		{Name: "channel", Type: "string", Description: "Channel ID", Required: true},
+	}},
+	"slack_conversations_history": {Method: "conversations.history", Description: "List recent messages in a channel", ReadOnly: true, ListKey: "messages", Params: append([]Param{
+		{Name: "channel", Type: "string", Description: "Channel ID", Required: true},
+		{Name: "oldest", Type: "string", Description: "Only messages after this ts"},
+	}, listParams...)},
+	"slack_conversations_join": {Method: "conversations.join", Description: "Join a public channel so the bot can post to it", Params: []Param{
+		{Name: "channel", Type: "string", Description: "Channel ID", Required: true},
+	}},
+
+	// Users
+	"slack_users_list": {Method: "users.list", Description: "List users in the workspace", ReadOnly: true, ListKey: "members", Params: listParams},
+	"slack_users_info": {Method: "users.info", Description: "Get a user", ReadOnly: true, Params: []Param{
+		{Name: "user", Type: "string", Description: "User ID", Required: true},
+	}},
+	"slack_users_lookup_by_email": {Method: "users.lookupByEmail", Description: "Find a user by email address", ReadOnly: true, Params: []Param{
+		{Name: "email", Type: "string", Required: true},
+	}},
+}
+
+// Arguments returns the JSON schema of the method's arguments
+func (m Method) Arguments() map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []interface{}{}
+	for _, p := range m.Params {
+		schema := map[string]interface{}{"type": p.Type}
+		if p.Description != "" {
+			schema["description"] = p.Description
+		}
+		properties[p.Name] = schema
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
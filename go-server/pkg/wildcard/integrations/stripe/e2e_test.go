@@ -0,0 +1,540 @@
+package stripe
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type args = map[string]interface{}
+
+// recordedRequest is a request received by the end-to-end backend
+type recordedRequest struct {
+	Method  string
+	Path    string
+	Params  url.Values
+	Account string
+}
+
+// newEndToEndBackend records every request and either forwards it to the
+// stripe-mock at STRIPE_MOCK_URL or answers with a generic object that decodes
+// as any Stripe resource or list
+func newEndToEndBackend(t *testing.T) (*httptest.Server, func() []recordedRequest) {
+	var mu sync.Mutex
+	var requests []recordedRequest
+
+	var proxy http.Handler
+	if mockURL := os.Getenv("STRIPE_MOCK_URL"); mockURL != "" {
+		target, err := url.Parse(mockURL)
+		require.NoError(t, err)
+		proxy = httputil.NewSingleHostReverseProxy(target)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(raw)))
+		params := r.URL.Query()
+		if r.Method == http.MethodPost {
+			params, _ = url.ParseQuery(string(raw))
+		}
+
+		mu.Lock()
+		requests = append(requests, recordedRequest{
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Params:  params,
+			Account: r.Header.Get("Stripe-Account"),
+		})
+		mu.Unlock()
+
+		if proxy != nil {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+		w.Write([]byte(`{"id": "obj_123", "object": "list", "data": [], "has_more": false}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), requests...)
+	}
+}
+
+// TestEndToEnd runs every function in FunctionMap against a Stripe backend and
+// checks the request the executor sends. Set STRIPE_MOCK_URL (e.g.
+// http://localhost:12111) to run against stripe-mock instead of the built-in
+// fake backend.
+func TestEndToEnd(t *testing.T) {
+	tests := []struct {
+		fn      string
+		args    args
+		method  string
+		path    string
+		params  url.Values
+		account string
+	}{
+		// Customers
+		{
+			fn:     "stripe_post_customers",
+			args:   args{"email": "jane@example.com", "name": "Jane", "metadata": args{"plan": "pro"}},
+			method: "POST", path: "/v1/customers",
+			params: url.Values{"email": {"jane@example.com"}, "name": {"Jane"}, "metadata[plan]": {"pro"}},
+		},
+		{
+			fn:     "stripe_get_customers",
+			args:   args{"email": "jane@example.com", "limit": 5.0},
+			method: "GET", path: "/v1/customers",
+			params: url.Values{"email": {"jane@example.com"}, "limit": {"5"}},
+		},
+		{
+			fn:     "stripe_get_customers_search",
+			args:   args{"query": "email:'jane@example.com'"},
+			method: "GET", path: "/v1/customers/search",
+			params: url.Values{"query": {"email:'jane@example.com'"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_get_customers_customer",
+			args:   args{"customer": "cus_123", "expand": []interface{}{"default_source"}},
+			method: "GET", path: "/v1/customers/cus_123",
+			params: url.Values{"expand[0]": {"default_source"}},
+		},
+
+		// Products
+		{
+			fn:     "stripe_post_products",
+			args:   args{"name": "Premium Plan", "description": "Monthly", "default_price_data": args{"currency": "usd", "unit_amount": 1000.0}},
+			method: "POST", path: "/v1/products",
+			params: url.Values{"name": {"Premium Plan"}, "description": {"Monthly"}, "default_price_data[currency]": {"usd"}, "default_price_data[unit_amount]": {"1000"}},
+		},
+		{
+			fn:     "stripe_get_products",
+			args:   args{"active": true, "ids": []interface{}{"prod_1", "prod_2"}, "shippable": true},
+			method: "GET", path: "/v1/products",
+			params: url.Values{"active": {"true"}, "ids[0]": {"prod_1"}, "ids[1]": {"prod_2"}, "shippable": {"true"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_post_products_id",
+			args:   args{"id": "prod_123", "name": "Premium Plan v2"},
+			method: "POST", path: "/v1/products/prod_123",
+			params: url.Values{"name": {"Premium Plan v2"}},
+		},
+		{
+			fn:     "stripe_get_products_id",
+			args:   args{"id": "prod_123"},
+			method: "GET", path: "/v1/products/prod_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_delete_products_id",
+			args:   args{"id": "prod_123"},
+			method: "DELETE", path: "/v1/products/prod_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_get_products_search",
+			args:   args{"query": "active:'true'", "page": "page_2", "limit": 250.0},
+			method: "GET", path: "/v1/products/search",
+			params: url.Values{"query": {"active:'true'"}, "page": {"page_2"}, "limit": {"100"}},
+		},
+
+		// Prices
+		{
+			fn:     "stripe_post_prices",
+			args:   args{"currency": "usd", "unit_amount": 1000.0, "product": "prod_123", "recurring": args{"interval": "month"}},
+			method: "POST", path: "/v1/prices",
+			params: url.Values{"currency": {"usd"}, "unit_amount": {"1000"}, "product": {"prod_123"}, "recurring[interval]": {"month"}},
+		},
+		{
+			fn:     "stripe_get_prices",
+			args:   args{"product": "prod_123", "type": "recurring"},
+			method: "GET", path: "/v1/prices",
+			params: url.Values{"product": {"prod_123"}, "type": {"recurring"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_get_prices_price",
+			args:   args{"price": "price_123"},
+			method: "GET", path: "/v1/prices/price_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_post_prices_price",
+			args:   args{"price": "price_123", "nickname": "Monthly", "active": false},
+			method: "POST", path: "/v1/prices/price_123",
+			params: url.Values{"nickname": {"Monthly"}, "active": {"false"}},
+		},
+		{
+			fn:     "stripe_get_prices_search",
+			args:   args{"query": "currency:'usd'"},
+			method: "GET", path: "/v1/prices/search",
+			params: url.Values{"query": {"currency:'usd'"}, "limit": {"100"}},
+		},
+
+		// Payment links and checkout
+		{
+			fn:     "stripe_post_payment_links",
+			args:   args{"line_items": []interface{}{args{"price": "price_123", "quantity": 2.0}}},
+			method: "POST", path: "/v1/payment_links",
+			params: url.Values{"line_items[0][price]": {"price_123"}, "line_items[0][quantity]": {"2"}},
+		},
+		{
+			fn: "stripe_post_checkout_sessions",
+			args: args{
+				"mode":        "payment",
+				"success_url": "https://example.com/success",
+				"line_items":  []interface{}{args{"price": "price_123", "quantity": 1.0}},
+			},
+			method: "POST", path: "/v1/checkout/sessions",
+			params: url.Values{"mode": {"payment"}, "success_url": {"https://example.com/success"}, "line_items[0][price]": {"price_123"}, "line_items[0][quantity]": {"1"}},
+		},
+
+		// Invoices
+		{
+			fn:     "stripe_post_invoices",
+			args:   args{"customer": "cus_123", "collection_method": "send_invoice", "days_until_due": 30.0},
+			method: "POST", path: "/v1/invoices",
+			params: url.Values{"customer": {"cus_123"}, "collection_method": {"send_invoice"}, "days_until_due": {"30"}},
+		},
+		{
+			fn:     "stripe_post_invoiceitems",
+			args:   args{"customer": "cus_123", "price": "price_123", "invoice": "in_123"},
+			method: "POST", path: "/v1/invoiceitems",
+			params: url.Values{"customer": {"cus_123"}, "price": {"price_123"}, "invoice": {"in_123"}},
+		},
+		{
+			fn:     "stripe_post_invoices_invoice_finalize",
+			args:   args{"invoice": "in_123", "auto_advance": true},
+			method: "POST", path: "/v1/invoices/in_123/finalize",
+			params: url.Values{"auto_advance": {"true"}},
+		},
+
+		// Balance
+		{
+			// Any function acts on a connected account through the Stripe-Account header
+			fn:     "stripe_get_balance",
+			args:   args{"stripe_account": "acct_123"},
+			method: "GET", path: "/v1/balance",
+			params:  url.Values{},
+			account: "acct_123",
+		},
+
+		// Refunds and credit notes
+		{
+			fn:     "stripe_post_refunds",
+			args:   args{"payment_intent": "pi_123", "amount": 500.0, "reason": "requested_by_customer"},
+			method: "POST", path: "/v1/refunds",
+			params: url.Values{"payment_intent": {"pi_123"}, "amount": {"500"}, "reason": {"requested_by_customer"}},
+		},
+		{
+			fn:     "stripe_get_refunds",
+			args:   args{"charge": "ch_123"},
+			method: "GET", path: "/v1/refunds",
+			params: url.Values{"charge": {"ch_123"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_get_refunds_refund",
+			args:   args{"refund": "re_123"},
+			method: "GET", path: "/v1/refunds/re_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_post_refunds_refund",
+			args:   args{"refund": "re_123", "metadata": args{"ticket": "T-1"}},
+			method: "POST", path: "/v1/refunds/re_123",
+			params: url.Values{"metadata[ticket]": {"T-1"}},
+		},
+		{
+			fn:     "stripe_post_refunds_refund_cancel",
+			args:   args{"refund": "re_123"},
+			method: "POST", path: "/v1/refunds/re_123/cancel",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_get_credit_notes_preview",
+			args:   args{"invoice": "in_123", "amount": 500.0},
+			method: "GET", path: "/v1/credit_notes/preview",
+			params: url.Values{"invoice": {"in_123"}, "amount": {"500"}},
+		},
+		{
+			fn:     "stripe_post_credit_notes",
+			args:   args{"invoice": "in_123", "lines": []interface{}{args{"type": "custom_line_item", "description": "Credit", "quantity": 1.0, "unit_amount": 500.0}}},
+			method: "POST", path: "/v1/credit_notes",
+			params: url.Values{
+				"invoice":               {"in_123"},
+				"lines[0][type]":        {"custom_line_item"},
+				"lines[0][description]": {"Credit"},
+				"lines[0][quantity]":    {"1"},
+				"lines[0][unit_amount]": {"500"},
+			},
+		},
+		{
+			fn:     "stripe_get_credit_notes",
+			args:   args{"invoice": "in_123"},
+			method: "GET", path: "/v1/credit_notes",
+			params: url.Values{"invoice": {"in_123"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_post_credit_notes_id_void",
+			args:   args{"id": "cn_123"},
+			method: "POST", path: "/v1/credit_notes/cn_123/void",
+			params: url.Values{},
+		},
+
+		// Billing portal
+		{
+			fn:     "stripe_post_billing_portal_sessions",
+			args:   args{"customer": "cus_123", "return_url": "https://example.com/account"},
+			method: "POST", path: "/v1/billing_portal/sessions",
+			params: url.Values{"customer": {"cus_123"}, "return_url": {"https://example.com/account"}},
+		},
+		{
+			fn:     "stripe_get_billing_portal_configurations",
+			args:   args{"is_default": true},
+			method: "GET", path: "/v1/billing_portal/configurations",
+			params: url.Values{"is_default": {"true"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_post_billing_portal_configurations",
+			args:   args{"features": args{"invoice_history": args{"enabled": true}}},
+			method: "POST", path: "/v1/billing_portal/configurations",
+			params: url.Values{"features[invoice_history][enabled]": {"true"}},
+		},
+
+		// Tax and shipping
+		{
+			fn:     "stripe_post_tax_rates",
+			args:   args{"display_name": "VAT", "inclusive": false, "percentage": 20.0, "country": "DE"},
+			method: "POST", path: "/v1/tax_rates",
+			params: url.Values{"display_name": {"VAT"}, "inclusive": {"false"}, "percentage": {"20.0000"}, "country": {"DE"}},
+		},
+		{
+			fn:     "stripe_get_tax_rates",
+			args:   args{"active": true},
+			method: "GET", path: "/v1/tax_rates",
+			params: url.Values{"active": {"true"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_get_tax_rates_tax_rate",
+			args:   args{"tax_rate": "txr_123"},
+			method: "GET", path: "/v1/tax_rates/txr_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_post_tax_rates_tax_rate",
+			args:   args{"tax_rate": "txr_123", "active": false},
+			method: "POST", path: "/v1/tax_rates/txr_123",
+			params: url.Values{"active": {"false"}},
+		},
+		{
+			fn:     "stripe_post_shipping_rates",
+			args:   args{"display_name": "Ground", "type": "fixed_amount", "fixed_amount": args{"amount": 500.0, "currency": "usd"}},
+			method: "POST", path: "/v1/shipping_rates",
+			params: url.Values{"display_name": {"Ground"}, "type": {"fixed_amount"}, "fixed_amount[amount]": {"500"}, "fixed_amount[currency]": {"usd"}},
+		},
+		{
+			fn:     "stripe_get_shipping_rates",
+			args:   args{"currency": "usd"},
+			method: "GET", path: "/v1/shipping_rates",
+			params: url.Values{"currency": {"usd"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_get_shipping_rates_shipping_rate_token",
+			args:   args{"shipping_rate_token": "shr_123"},
+			method: "GET", path: "/v1/shipping_rates/shr_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_post_shipping_rates_shipping_rate_token",
+			args:   args{"shipping_rate_token": "shr_123", "active": false},
+			method: "POST", path: "/v1/shipping_rates/shr_123",
+			params: url.Values{"active": {"false"}},
+		},
+		{
+			fn: "stripe_post_tax_calculations",
+			args: args{
+				"currency":         "usd",
+				"line_items":       []interface{}{args{"amount": 1000.0, "reference": "L1"}},
+				"customer_details": args{"address": args{"country": "US", "postal_code": "10001"}, "address_source": "shipping"},
+			},
+			method: "POST", path: "/v1/tax/calculations",
+			params: url.Values{
+				"currency":                               {"usd"},
+				"line_items[0][amount]":                  {"1000"},
+				"line_items[0][reference]":               {"L1"},
+				"customer_details[address][country]":     {"US"},
+				"customer_details[address][postal_code]": {"10001"},
+				"customer_details[address_source]":       {"shipping"},
+			},
+		},
+		{
+			fn:     "stripe_get_tax_calculations_calculation_line_items",
+			args:   args{"calculation": "taxcalc_123"},
+			method: "GET", path: "/v1/tax/calculations/taxcalc_123/line_items",
+			params: url.Values{"limit": {"100"}},
+		},
+		{
+			fn:     "stripe_post_tax_transactions_create_from_calculation",
+			args:   args{"calculation": "taxcalc_123", "reference": "order_1"},
+			method: "POST", path: "/v1/tax/transactions/create_from_calculation",
+			params: url.Values{"calculation": {"taxcalc_123"}, "reference": {"order_1"}},
+		},
+		{
+			fn:     "stripe_post_tax_transactions_create_reversal",
+			args:   args{"mode": "full", "original_transaction": "tax_123", "reference": "order_1-refund"},
+			method: "POST", path: "/v1/tax/transactions/create_reversal",
+			params: url.Values{"mode": {"full"}, "original_transaction": {"tax_123"}, "reference": {"order_1-refund"}},
+		},
+		{
+			fn:     "stripe_get_tax_transactions_transaction",
+			args:   args{"transaction": "tax_123"},
+			method: "GET", path: "/v1/tax/transactions/tax_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_get_tax_transactions_transaction_line_items",
+			args:   args{"transaction": "tax_123", "limit": 3.0},
+			method: "GET", path: "/v1/tax/transactions/tax_123/line_items",
+			params: url.Values{"limit": {"3"}},
+		},
+
+		// Connect
+		{
+			fn:     "stripe_get_accounts",
+			args:   args{},
+			method: "GET", path: "/v1/accounts",
+			params: url.Values{"limit": {"100"}},
+		},
+		{
+			fn:     "stripe_get_accounts_account",
+			args:   args{"account": "acct_123"},
+			method: "GET", path: "/v1/accounts/acct_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_post_accounts",
+			args:   args{"type": "express", "country": "US", "email": "shop@example.com"},
+			method: "POST", path: "/v1/accounts",
+			params: url.Values{"type": {"express"}, "country": {"US"}, "email": {"shop@example.com"}},
+		},
+		{
+			fn:     "stripe_post_accounts_account",
+			args:   args{"account": "acct_123", "business_profile": args{"name": "Shop"}},
+			method: "POST", path: "/v1/accounts/acct_123",
+			params: url.Values{"business_profile[name]": {"Shop"}},
+		},
+		{
+			fn: "stripe_post_account_links",
+			args: args{
+				"account":     "acct_123",
+				"type":        "account_onboarding",
+				"refresh_url": "https://example.com/refresh",
+				"return_url":  "https://example.com/return",
+			},
+			method: "POST", path: "/v1/account_links",
+			params: url.Values{"account": {"acct_123"}, "type": {"account_onboarding"}, "refresh_url": {"https://example.com/refresh"}, "return_url": {"https://example.com/return"}},
+		},
+		{
+			fn:     "stripe_post_accounts_account_login_links",
+			args:   args{"account": "acct_123"},
+			method: "POST", path: "/v1/accounts/acct_123/login_links",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_post_transfers",
+			args:   args{"amount": 1000.0, "currency": "usd", "destination": "acct_123"},
+			method: "POST", path: "/v1/transfers",
+			params: url.Values{"amount": {"1000"}, "currency": {"usd"}, "destination": {"acct_123"}},
+		},
+		{
+			fn:     "stripe_get_transfers",
+			args:   args{"destination": "acct_123"},
+			method: "GET", path: "/v1/transfers",
+			params: url.Values{"destination": {"acct_123"}, "limit": {"100"}},
+		},
+		{
+			fn:     "stripe_get_transfers_transfer",
+			args:   args{"transfer": "tr_123"},
+			method: "GET", path: "/v1/transfers/tr_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_get_customers",
+			args:   args{"stripe_account": "acct_123"},
+			method: "GET", path: "/v1/customers",
+			params: url.Values{"limit": {"100"}}, account: "acct_123",
+		},
+
+		// Test clocks
+		{
+			fn:     "stripe_post_test_helpers_test_clocks",
+			args:   args{"frozen_time": 1700000000.0, "name": "Renewal"},
+			method: "POST", path: "/v1/test_helpers/test_clocks",
+			params: url.Values{"frozen_time": {"1700000000"}, "name": {"Renewal"}},
+		},
+		{
+			fn:     "stripe_get_test_helpers_test_clocks",
+			args:   args{},
+			method: "GET", path: "/v1/test_helpers/test_clocks",
+			params: url.Values{"limit": {"100"}},
+		},
+		{
+			fn:     "stripe_get_test_helpers_test_clocks_test_clock",
+			args:   args{"test_clock": "clock_123"},
+			method: "GET", path: "/v1/test_helpers/test_clocks/clock_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_delete_test_helpers_test_clocks_test_clock",
+			args:   args{"test_clock": "clock_123"},
+			method: "DELETE", path: "/v1/test_helpers/test_clocks/clock_123",
+			params: url.Values{},
+		},
+		{
+			fn:     "stripe_post_test_helpers_test_clocks_test_clock_advance",
+			args:   args{"test_clock": "clock_123", "frozen_time": 1700086400.0},
+			method: "POST", path: "/v1/test_helpers/test_clocks/clock_123/advance",
+			params: url.Values{"frozen_time": {"1700086400"}},
+		},
+	}
+
+	server, requests := newEndToEndBackend(t)
+	useTestBackend(t, server.URL)
+	executor := NewExecutor(fakeKeyStore{"user": "sk_test_123"})
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[tt.fn] = true
+		t.Run(tt.fn, func(t *testing.T) {
+			before := len(requests())
+			_, err := executor.ExecuteFunction("user", tt.fn, tt.args)
+			require.NoError(t, err)
+
+			sent := requests()[before:]
+			require.Len(t, sent, 1, "exactly one request should be sent")
+			assert.Equal(t, tt.method, sent[0].Method)
+			assert.Equal(t, tt.path, sent[0].Path)
+			assert.Equal(t, tt.params, sent[0].Params)
+			assert.Equal(t, tt.account, sent[0].Account)
+		})
+	}
+
+	for fn := range FunctionMap {
+		assert.True(t, covered[fn], "%s has no end-to-end test", fn)
+	}
+
+	// A search without a query is not sent
+	before := len(requests())
+	_, err := executor.ExecuteFunction("user", "stripe_get_prices_search", args{})
+	assert.EqualError(t, err, "invalid arguments: missing required fields: query")
+	assert.Len(t, requests(), before)
+}
@@ -2,93 +2,294 @@ package stripe
 
 import (
 	"fmt"
+	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/stripe/stripe-go/v81"
-	"github.com/stripe/stripe-go/v81/balance"
-	portalconfig "github.com/stripe/stripe-go/v81/billingportal/configuration"
-	portalsession "github.com/stripe/stripe-go/v81/billingportal/session"
-	checkoutsession "github.com/stripe/stripe-go/v81/checkout/session"
-	"github.com/stripe/stripe-go/v81/customer"
-	"github.com/stripe/stripe-go/v81/invoice"
-	"github.com/stripe/stripe-go/v81/invoiceitem"
-	"github.com/stripe/stripe-go/v81/paymentlink"
-	"github.com/stripe/stripe-go/v81/price"
-	"github.com/stripe/stripe-go/v81/product"
-	"github.com/stripe/stripe-go/v81/refund"
+	"github.com/stripe/stripe-go/v81/client"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
 )
 
 // Executor handles Stripe API operations
 type Executor struct {
-	keyStore StripeKeyStoreInterface
+	credentials CredentialStore
 }
 
-// StripeKeyStoreInterface defines the interface for storing and retrieving Stripe API keys
-type StripeKeyStoreInterface interface {
-	GetStripeKey(userID string) (string, error)
+// CredentialStore defines the interface for resolving users' Stripe credentials.
+// An empty name selects the user's default credential.
+type CredentialStore interface {
+	StripeCredential(userID, name string) (*wildcard.Credential, error)
 }
 
 // NewExecutor creates a new Stripe executor
-func NewExecutor(keyStore StripeKeyStoreInterface) *Executor {
+func NewExecutor(credentials CredentialStore) *Executor {
 	return &Executor{
-		keyStore: keyStore,
+		credentials: credentials,
 	}
 }
 
-// setStripeKey sets the Stripe API key for the current operation
-func (e *Executor) setStripeKey(userID string) error {
-	key, err := e.keyStore.GetStripeKey(userID)
+func init() {
+	UseHTTPClient(nil)
+}
+
+// UseHTTPClient routes all Stripe API requests through the given HTTP client,
+// e.g. to record or replay them. A nil client uses stripe-go's default. The
+// executor retries failed calls itself, so stripe-go's network retries are
+// turned off.
+func UseHTTPClient(client *http.Client) {
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		HTTPClient:        client,
+		MaxNetworkRetries: stripe.Int64(0),
+	}))
+}
+
+// credential resolves the Stripe credential of the current operation, named by
+// the credential argument or the user's default
+func (e *Executor) credential(userID string, params map[string]interface{}) (*wildcard.Credential, error) {
+	name, _ := params[wildcard.ArgCredential].(string)
+	credential, err := e.credentials.StripeCredential(userID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Stripe API key for user %s: %v", userID, err)
+	}
+	return credential, nil
+}
+
+// bindCredential resolves the credential of the current operation. A credential
+// bound to a connected account always acts on it, so a stripe_account argument
+// naming another account is refused.
+func (e *Executor) bindCredential(userID string, params map[string]interface{}) (*wildcard.Credential, error) {
+	credential, err := e.credential(userID, params)
+	if err != nil {
+		return nil, err
+	}
+	if credential.Account == "" {
+		return credential, nil
+	}
+	if account, ok := params["stripe_account"]; ok && account != credential.Account {
+		return nil, fmt.Errorf("credential %s can only act on its connected account %s", credential.Name, credential.Account)
+	}
+	params["stripe_account"] = credential.Account
+	return credential, nil
+}
+
+// stripeClient returns a client using the Stripe API key of the current
+// operation. Each call gets its own client, so that concurrent calls for
+// different users never share a key.
+func (e *Executor) stripeClient(userID string, params map[string]interface{}) (*client.API, error) {
+	credential, err := e.bindCredential(userID, params)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(credential.Secret, nil), nil
+}
+
+// requireTestMode refuses the operation unless the user's Stripe API key is a test mode key
+func (e *Executor) requireTestMode(userID string, params map[string]interface{}) error {
+	credential, err := e.credential(userID, params)
+	if err != nil {
+		return err
+	}
+	if key := credential.Secret; strings.HasPrefix(key, "sk_live_") || strings.HasPrefix(key, "rk_live_") {
+		return fmt.Errorf("test helpers are not available with a live mode API key")
+	}
+	return nil
+}
+
+// SendsIdempotencyKey reports whether calls of the function send an idempotency
+// key, which Stripe only applies to POSTs
+func (e *Executor) SendsIdempotencyKey(name string) bool {
+	ops, err := Operations()
 	if err != nil {
-		return fmt.Errorf("failed to get Stripe API key for user %s: %v", userID, err)
+		return false
+	}
+	op, ok := ops[name]
+	return ok && op.Method == http.MethodPost
+}
+
+// prepareIdempotencyKey removes the idempotency key from calls that are not POSTs,
+// since Stripe only applies idempotency to mutating requests, and gives POSTs
+// without one a key so that retries reuse it
+func prepareIdempotencyKey(op Operation, args map[string]interface{}) error {
+	if op.Method != http.MethodPost {
+		delete(args, wildcard.ArgIdempotencyKey)
+		return nil
+	}
+	if key, ok := args[wildcard.ArgIdempotencyKey].(string); !ok || key == "" {
+		key, err := newIdempotencyKey()
+		if err != nil {
+			return err
+		}
+		args[wildcard.ArgIdempotencyKey] = key
 	}
-	stripe.Key = key
 	return nil
 }
 
-// FunctionMap maps operation IDs to their corresponding functions
+// FunctionMap maps operation IDs to their corresponding functions. These take
+// precedence over the generic operations loaded from the OpenAPI spec.
 var FunctionMap = map[string]interface{}{
-	"stripe_post_customers":                     (*Executor).CreateCustomer,
-	"stripe_get_customers":                      (*Executor).ListCustomers,
-	"stripe_post_products":                      (*Executor).CreateProduct,
-	"stripe_get_products":                       (*Executor).ListProducts,
-	"stripe_post_prices":                        (*Executor).CreatePrice,
-	"stripe_get_prices":                         (*Executor).ListPrices,
-	"stripe_post_payment_links":                 (*Executor).CreatePaymentLink,
-	"stripe_post_invoices":                      (*Executor).CreateInvoice,
-	"stripe_post_invoiceitems":                  (*Executor).CreateInvoiceItem,
-	"stripe_post_invoices_invoice_finalize":     (*Executor).FinalizeInvoice,
-	"stripe_get_balance":                        (*Executor).GetBalance,
-	"stripe_post_refunds":                       (*Executor).CreateRefund,
-	"stripe_post_products_id":                   (*Executor).UpdateProduct,
-	"stripe_get_products_id":                    (*Executor).GetProduct,
-	"stripe_post_checkout_sessions":             (*Executor).CreateCheckoutSession,
-	"stripe_post_billing_portal_sessions":       (*Executor).CreateBillingPortalSession,
-	"stripe_get_prices_price":                   (*Executor).GetPrice,
-	"stripe_post_prices_price":                  (*Executor).UpdatePrice,
-	"stripe_get_customers_search":               (*Executor).SearchCustomers,
-	"stripe_get_customers_customer":             (*Executor).GetCustomer,
-	"stripe_get_billing_portal_configurations":  (*Executor).ListBillingPortalConfigurations,
-	"stripe_post_billing_portal_configurations": (*Executor).CreateBillingPortalConfiguration,
-}
-
-// ExecuteFunction executes a Stripe function by name with given arguments
+	"stripe_post_customers":                                   (*Executor).CreateCustomer,
+	"stripe_get_customers":                                    (*Executor).ListCustomers,
+	"stripe_post_products":                                    (*Executor).CreateProduct,
+	"stripe_get_products":                                     (*Executor).ListProducts,
+	"stripe_post_prices":                                      (*Executor).CreatePrice,
+	"stripe_get_prices":                                       (*Executor).ListPrices,
+	"stripe_post_payment_links":                               (*Executor).CreatePaymentLink,
+	"stripe_post_invoices":                                    (*Executor).CreateInvoice,
+	"stripe_post_invoiceitems":                                (*Executor).CreateInvoiceItem,
+	"stripe_post_invoices_invoice_finalize":                   (*Executor).FinalizeInvoice,
+	"stripe_get_balance":                                      (*Executor).GetBalance,
+	"stripe_get_refunds":                                      (*Executor).ListRefunds,
+	"stripe_get_refunds_refund":                               (*Executor).GetRefund,
+	"stripe_post_refunds_refund":                              (*Executor).UpdateRefund,
+	"stripe_post_refunds_refund_cancel":                       (*Executor).CancelRefund,
+	"stripe_get_credit_notes_preview":                         (*Executor).PreviewCreditNote,
+	"stripe_post_credit_notes":                                (*Executor).CreateCreditNote,
+	"stripe_get_credit_notes":                                 (*Executor).ListCreditNotes,
+	"stripe_post_credit_notes_id_void":                        (*Executor).VoidCreditNote,
+	"stripe_post_refunds":                                     (*Executor).CreateRefund,
+	"stripe_post_products_id":                                 (*Executor).UpdateProduct,
+	"stripe_get_products_id":                                  (*Executor).GetProduct,
+	"stripe_delete_products_id":                               (*Executor).DeleteProduct,
+	"stripe_get_products_search":                              (*Executor).SearchProducts,
+	"stripe_get_prices_search":                                (*Executor).SearchPrices,
+	"stripe_post_checkout_sessions":                           (*Executor).CreateCheckoutSession,
+	"stripe_post_billing_portal_sessions":                     (*Executor).CreateBillingPortalSession,
+	"stripe_get_prices_price":                                 (*Executor).GetPrice,
+	"stripe_post_prices_price":                                (*Executor).UpdatePrice,
+	"stripe_get_customers_search":                             (*Executor).SearchCustomers,
+	"stripe_get_customers_customer":                           (*Executor).GetCustomer,
+	"stripe_get_billing_portal_configurations":                (*Executor).ListBillingPortalConfigurations,
+	"stripe_post_billing_portal_configurations":               (*Executor).CreateBillingPortalConfiguration,
+	"stripe_post_tax_rates":                                   (*Executor).CreateTaxRate,
+	"stripe_get_tax_rates":                                    (*Executor).ListTaxRates,
+	"stripe_get_tax_rates_tax_rate":                           (*Executor).GetTaxRate,
+	"stripe_post_tax_rates_tax_rate":                          (*Executor).UpdateTaxRate,
+	"stripe_post_shipping_rates":                              (*Executor).CreateShippingRate,
+	"stripe_get_shipping_rates":                               (*Executor).ListShippingRates,
+	"stripe_get_shipping_rates_shipping_rate_token":           (*Executor).GetShippingRate,
+	"stripe_post_shipping_rates_shipping_rate_token":          (*Executor).UpdateShippingRate,
+	"stripe_post_tax_calculations":                            (*Executor).CreateTaxCalculation,
+	"stripe_get_tax_calculations_calculation_line_items":      (*Executor).ListTaxCalculationLineItems,
+	"stripe_post_tax_transactions_create_from_calculation":    (*Executor).CreateTaxTransactionFromCalculation,
+	"stripe_post_tax_transactions_create_reversal":            (*Executor).CreateTaxTransactionReversal,
+	"stripe_get_tax_transactions_transaction":                 (*Executor).GetTaxTransaction,
+	"stripe_get_tax_transactions_transaction_line_items":      (*Executor).ListTaxTransactionLineItems,
+	"stripe_get_accounts":                                     (*Executor).ListAccounts,
+	"stripe_get_accounts_account":                             (*Executor).GetAccount,
+	"stripe_post_accounts":                                    (*Executor).CreateAccount,
+	"stripe_post_accounts_account":                            (*Executor).UpdateAccount,
+	"stripe_post_account_links":                               (*Executor).CreateAccountLink,
+	"stripe_post_accounts_account_login_links":                (*Executor).CreateLoginLink,
+	"stripe_post_transfers":                                   (*Executor).CreateTransfer,
+	"stripe_get_transfers":                                    (*Executor).ListTransfers,
+	"stripe_get_transfers_transfer":                           (*Executor).GetTransfer,
+	"stripe_post_test_helpers_test_clocks":                    (*Executor).CreateTestClock,
+	"stripe_get_test_helpers_test_clocks":                     (*Executor).ListTestClocks,
+	"stripe_get_test_helpers_test_clocks_test_clock":          (*Executor).GetTestClock,
+	"stripe_delete_test_helpers_test_clocks_test_clock":       (*Executor).DeleteTestClock,
+	"stripe_post_test_helpers_test_clocks_test_clock_advance": (*Executor).AdvanceTestClock,
+}
+
+// Description describes the Stripe integration
+func (e *Executor) Description() string {
+	return "Stripe payments and billing: customers, products, prices, payment links, invoices, subscriptions, refunds, taxes and Connect accounts"
+}
+
+// Catalog lists every operation in the curated Stripe spec. GET operations are
+// read-only.
+func (e *Executor) Catalog() []wildcard.FunctionInfo {
+	ops, err := Operations()
+	if err != nil {
+		return nil
+	}
+	catalog := make([]wildcard.FunctionInfo, 0, len(ops))
+	for name, op := range ops {
+		catalog = append(catalog, wildcard.FunctionInfo{
+			Name:        name,
+			Description: op.Description,
+			Arguments:   op.Arguments,
+			ReadOnly:    op.Method == http.MethodGet,
+		})
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+	return catalog
+}
+
+// ExecuteFunction executes a Stripe function by name with given arguments.
+// Any function can act on a connected account by passing its ID as the
+// stripe_account argument, and use one of the user's named credentials by
+// passing its name as the credential argument.
 func (e *Executor) ExecuteFunction(userID string, name string, args map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	if _, err := e.bindCredential(userID, args); err != nil {
 		return nil, err
 	}
 
+	ops, err := Operations()
+	if err != nil {
+		return nil, err
+	}
+	op, hasOp := ops[name]
 	fn, exists := FunctionMap[name]
-	if !exists {
+	if !exists && !hasOp {
 		return nil, fmt.Errorf("unknown function: %s", name)
 	}
 
+	if hasOp {
+		// Hand-written methods validate their arguments against stripe-go params
+		validate := validateRequired
+		if !exists {
+			validate = validateOperation
+		}
+		if err := validate(op, args); err != nil {
+			return nil, err
+		}
+		if err := prepareIdempotencyKey(op, args); err != nil {
+			return nil, err
+		}
+	}
+
+	if !exists {
+		// Fall back to the OpenAPI spec for operations without a hand-written method
+		return withRetry(args, func(attemptArgs map[string]interface{}) (interface{}, error) {
+			credential, err := e.bindCredential(userID, attemptArgs)
+			if err != nil {
+				return nil, err
+			}
+			return executeOperation(op, attemptArgs, credential.Secret)
+		})
+	}
+
 	method := fn.(func(*Executor, string, map[string]interface{}) (interface{}, error))
-	return method(e, userID, args)
+	return withRetry(args, func(attemptArgs map[string]interface{}) (interface{}, error) {
+		return method(e, userID, attemptArgs)
+	})
 }
 
-// convertToStripeParams converts a map[string]interface{} to a Stripe params struct using reflection
+// convertToStripeParams validates the arguments against a Stripe params struct and
+// converts them into it. Unknown fields and type mismatches are reported as a
+// ValidationError instead of being dropped.
 func convertToStripeParams(params map[string]interface{}, target interface{}) error {
+	if err := validateParams(params, target); err != nil {
+		return err
+	}
+	if err := fillParams(params, target); err != nil {
+		return err
+	}
+
+	// List and search calls fetch a single capped page so Wildcard pages deliberately
+	if container, ok := target.(stripe.ListParamsContainer); ok {
+		limitListPage(container.GetListParams())
+	}
+	if container, ok := target.(stripe.SearchParamsContainer); ok {
+		limitSearchPage(container.GetSearchParams())
+	}
+	return nil
+}
+
+// fillParams converts a map[string]interface{} to a Stripe params struct using reflection
+func fillParams(params map[string]interface{}, target interface{}) error {
 	targetValue := reflect.ValueOf(target).Elem()
 	targetType := targetValue.Type()
 
@@ -117,6 +318,20 @@ func convertToStripeParams(params map[string]interface{}, target interface{}) er
 		}
 	}
 
+	// Handle the connected account the call should act on, sent as the Stripe-Account header
+	if account, ok := params["stripe_account"].(string); ok && account != "" {
+		if method := reflect.ValueOf(target).MethodByName("SetStripeAccount"); method.IsValid() {
+			method.Call([]reflect.Value{reflect.ValueOf(account)})
+		}
+	}
+
+	// Handle the idempotency key of a mutating call, sent as the Idempotency-Key header
+	if key, ok := params[wildcard.ArgIdempotencyKey].(string); ok && key != "" {
+		if method := reflect.ValueOf(target).MethodByName("SetIdempotencyKey"); method.IsValid() {
+			method.Call([]reflect.Value{reflect.ValueOf(key)})
+		}
+	}
+
 	for i := 0; i < targetType.NumField(); i++ {
 		field := targetType.Field(i)
 		formTag := field.Tag.Get("form")
@@ -124,7 +339,16 @@ func convertToStripeParams(params map[string]interface{}, target interface{}) er
 			continue
 		}
 		formName := strings.Split(formTag, ",")[0]
-		if formName == "*" || formName == "-" {
+		if formName == "-" {
+			continue
+		}
+
+		// Embedded structs such as ListParams and SearchParams are flattened
+		// into the parent's form, so fill them from the same arguments
+		if formName == "*" {
+			if err := convertEmbeddedParams(params, targetValue, i); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -134,87 +358,207 @@ func convertToStripeParams(params map[string]interface{}, target interface{}) er
 				continue
 			}
 
-			switch fieldValue.Type().String() {
-			case "*string":
-				if strVal, ok := value.(string); ok {
-					fieldValue.Set(reflect.ValueOf(stripe.String(strVal)))
-				}
-			case "*int64":
-				switch v := value.(type) {
-				case float64:
-					fieldValue.Set(reflect.ValueOf(stripe.Int64(int64(v))))
-				case int:
-					fieldValue.Set(reflect.ValueOf(stripe.Int64(int64(v))))
-				}
-			case "*bool":
-				if boolVal, ok := value.(bool); ok {
-					fieldValue.Set(reflect.ValueOf(stripe.Bool(boolVal)))
-				}
-			case "[]*string":
-				if arr, ok := value.([]interface{}); ok {
-					strArr := make([]*string, len(arr))
-					for i, v := range arr {
-						if strVal, ok := v.(string); ok {
-							strArr[i] = stripe.String(strVal)
-						}
-					}
-					fieldValue.Set(reflect.ValueOf(strArr))
-				}
-			case "map[string]string":
-				if mapVal, ok := value.(map[string]interface{}); ok {
-					strMap := make(map[string]string)
-					for k, v := range mapVal {
-						if strVal, ok := v.(string); ok {
-							strMap[k] = strVal
-						}
-					}
-					fieldValue.Set(reflect.ValueOf(strMap))
-				}
-			default:
-				// Handle nested structs
-				if fieldValue.Kind() == reflect.Ptr {
-					if nestedMap, ok := value.(map[string]interface{}); ok {
-						nestedType := fieldValue.Type().Elem()
-						nestedValue := reflect.New(nestedType)
-						if err := convertToStripeParams(nestedMap, nestedValue.Interface()); err != nil {
-							return err
-						}
-						fieldValue.Set(nestedValue)
-					}
-				} else if fieldValue.Kind() == reflect.Struct {
-					if nestedMap, ok := value.(map[string]interface{}); ok {
-						nestedValue := reflect.New(fieldValue.Type())
-						if err := convertToStripeParams(nestedMap, nestedValue.Interface()); err != nil {
-							return err
-						}
-						fieldValue.Set(nestedValue.Elem())
-					}
-				}
+			if _, err := assignValue(fieldValue, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// assignValue converts a decoded JSON value into dst according to dst's kind and
+// reports whether it was assigned. Values of the wrong shape are left unset.
+func assignValue(dst reflect.Value, value interface{}) (bool, error) {
+	switch dst.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		ok, err := assignValue(elem.Elem(), value)
+		if ok && err == nil {
+			dst.Set(elem)
+		}
+		return ok, err
+	case reflect.String:
+		// Also covers enum types declared as named strings
+		if v, ok := value.(string); ok {
+			dst.SetString(v)
+			return true, nil
+		}
+	case reflect.Bool:
+		if v, ok := value.(bool); ok {
+			dst.SetBool(v)
+			return true, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := value.(type) {
+		case float64:
+			dst.SetInt(int64(v))
+			return true, nil
+		case int:
+			dst.SetInt(int64(v))
+			return true, nil
+		case int64:
+			dst.SetInt(v)
+			return true, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := value.(type) {
+		case float64:
+			dst.SetFloat(v)
+			return true, nil
+		case int:
+			dst.SetFloat(float64(v))
+			return true, nil
+		case int64:
+			dst.SetFloat(float64(v))
+			return true, nil
+		}
+	case reflect.Slice:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return false, nil
+		}
+		slice := reflect.MakeSlice(dst.Type(), 0, len(arr))
+		for _, v := range arr {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			ok, err := assignValue(elem, v)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				slice = reflect.Append(slice, elem)
+			}
+		}
+		dst.Set(slice)
+		return true, nil
+	case reflect.Array:
+		arr, ok := value.([]interface{})
+		if !ok || len(arr) > dst.Len() {
+			return false, nil
+		}
+		for i, v := range arr {
+			if _, err := assignValue(dst.Index(i), v); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case reflect.Map:
+		m, ok := value.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return false, nil
+		}
+		mapValue := reflect.MakeMapWithSize(dst.Type(), len(m))
+		for k, v := range m {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			ok, err := assignValue(elem, v)
+			if err != nil {
+				return false, err
 			}
+			if ok {
+				mapValue.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+			}
+		}
+		dst.Set(mapValue)
+		return true, nil
+	case reflect.Struct:
+		nestedMap, ok := value.(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		if err := fillParams(nestedMap, dst.Addr().Interface()); err != nil {
+			return false, err
+		}
+		return true, nil
+	case reflect.Interface:
+		if value != nil {
+			dst.Set(reflect.ValueOf(value))
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// convertEmbeddedParams fills the embedded struct at field index i of parent.
+// Arguments matching a form name declared on the parent itself are left to the
+// parent so that shared fields like expand are not encoded twice.
+func convertEmbeddedParams(params map[string]interface{}, parent reflect.Value, i int) error {
+	fieldValue := parent.Field(i)
+	if !fieldValue.CanSet() {
+		return nil
+	}
+
+	parentType := parent.Type()
+	embeddedParams := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		embeddedParams[k] = v
+	}
+	for j := 0; j < parentType.NumField(); j++ {
+		if j == i {
+			continue
+		}
+		name := strings.Split(parentType.Field(j).Tag.Get("form"), ",")[0]
+		delete(embeddedParams, name)
+	}
+	delete(embeddedParams, "metadata")
+	delete(embeddedParams, "expand")
+	delete(embeddedParams, "stripe_account")
+	delete(embeddedParams, wildcard.ArgIdempotencyKey)
+	delete(embeddedParams, wildcard.ArgCredential)
+
+	switch fieldValue.Kind() {
+	case reflect.Struct:
+		return fillParams(embeddedParams, fieldValue.Addr().Interface())
+	case reflect.Ptr:
+		if fieldValue.Type().Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		nestedValue := reflect.New(fieldValue.Type().Elem())
+		if err := fillParams(embeddedParams, nestedValue.Interface()); err != nil {
+			return err
+		}
+		if !nestedValue.Elem().IsZero() {
+			fieldValue.Set(nestedValue)
 		}
 	}
 	return nil
 }
 
 func (e *Executor) CreateCustomer(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
 	p := &stripe.CustomerParams{}
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return customer.New(p)
+
+	// Customers are attached to a test clock at creation
+	if p.TestClock != nil {
+		if err := e.requireTestMode(userID, params); err != nil {
+			return nil, err
+		}
+	}
+	return sc.Customers.New(p)
 }
 
 func (e *Executor) ListCustomers(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
 	p := &stripe.CustomerListParams{}
-	if limit, ok := params["limit"].(float64); ok {
-		p.Limit = stripe.Int64(int64(limit))
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
 	}
-	i := customer.List(p)
+	i := sc.Customers.List(p)
 	return collectResults(i)
 }
 
 func (e *Executor) CreateProduct(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
@@ -222,25 +566,26 @@ func (e *Executor) CreateProduct(userID string, params map[string]interface{}) (
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	fmt.Printf("Product params: %+v\n", p)
-	return product.New(p)
+	return sc.Products.New(p)
 }
 
 func (e *Executor) ListProducts(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
 	p := &stripe.ProductListParams{}
-	if active, ok := params["active"].(bool); ok {
-		p.Active = stripe.Bool(active)
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
 	}
-	i := product.List(p)
+	i := sc.Products.List(p)
 	return collectResults(i)
 }
 
 func (e *Executor) CreatePrice(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
@@ -248,11 +593,12 @@ func (e *Executor) CreatePrice(userID string, params map[string]interface{}) (in
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return price.New(p)
+	return sc.Prices.New(p)
 }
 
 func (e *Executor) ListPrices(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
@@ -260,12 +606,13 @@ func (e *Executor) ListPrices(userID string, params map[string]interface{}) (int
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	i := price.List(p)
+	i := sc.Prices.List(p)
 	return collectResults(i)
 }
 
 func (e *Executor) CreatePaymentLink(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
@@ -273,11 +620,12 @@ func (e *Executor) CreatePaymentLink(userID string, params map[string]interface{
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return paymentlink.New(p)
+	return sc.PaymentLinks.New(p)
 }
 
 func (e *Executor) CreateInvoice(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
@@ -285,11 +633,12 @@ func (e *Executor) CreateInvoice(userID string, params map[string]interface{}) (
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return invoice.New(p)
+	return sc.Invoices.New(p)
 }
 
 func (e *Executor) CreateInvoiceItem(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
@@ -297,11 +646,12 @@ func (e *Executor) CreateInvoiceItem(userID string, params map[string]interface{
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return invoiceitem.New(p)
+	return sc.InvoiceItems.New(p)
 }
 
 func (e *Executor) FinalizeInvoice(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
@@ -309,19 +659,31 @@ func (e *Executor) FinalizeInvoice(userID string, params map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invoice ID is required")
 	}
-	return invoice.FinalizeInvoice(id, nil)
+	delete(params, "invoice")
+
+	p := &stripe.InvoiceFinalizeInvoiceParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Invoices.FinalizeInvoice(id, p)
 }
 
 func (e *Executor) GetBalance(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	return balance.Get(nil)
+	p := &stripe.BalanceParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Balance.Get(p)
 }
 
 func (e *Executor) CreateRefund(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
@@ -329,175 +691,848 @@ func (e *Executor) CreateRefund(userID string, params map[string]interface{}) (i
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return refund.New(p)
+	return sc.Refunds.New(p)
 }
 
-func (e *Executor) UpdateProduct(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) ListRefunds(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	id, ok := params["id"].(string)
+	p := &stripe.RefundListParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.Refunds.List(p)
+	return collectResults(i)
+}
+
+func (e *Executor) GetRefund(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["refund"].(string)
 	if !ok {
-		return nil, fmt.Errorf("product ID is required")
+		return nil, fmt.Errorf("refund ID is required")
 	}
-	delete(params, "id")
+	delete(params, "refund")
 
-	p := &stripe.ProductParams{}
+	p := &stripe.RefundParams{}
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return product.Update(id, p)
+	return sc.Refunds.Get(id, p)
 }
 
-func (e *Executor) GetProduct(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) UpdateRefund(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	id, ok := params["id"].(string)
+	id, ok := params["refund"].(string)
 	if !ok {
-		return nil, fmt.Errorf("product ID is required")
+		return nil, fmt.Errorf("refund ID is required")
+	}
+	delete(params, "refund")
+
+	p := &stripe.RefundParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
 	}
-	return product.Get(id, nil)
+	return sc.Refunds.Update(id, p)
 }
 
-func (e *Executor) CreateCheckoutSession(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) CancelRefund(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	p := &stripe.CheckoutSessionParams{}
+	id, ok := params["refund"].(string)
+	if !ok {
+		return nil, fmt.Errorf("refund ID is required")
+	}
+	delete(params, "refund")
+
+	p := &stripe.RefundCancelParams{}
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return checkoutsession.New(p)
+	return sc.Refunds.Cancel(id, p)
 }
 
-func (e *Executor) CreateBillingPortalSession(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) PreviewCreditNote(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	p := &stripe.BillingPortalSessionParams{}
+	p := &stripe.CreditNotePreviewParams{}
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return portalsession.New(p)
+	return sc.CreditNotes.Preview(p)
 }
 
-func (e *Executor) GetPrice(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) CreateCreditNote(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	id, ok := params["price"].(string)
+	p := &stripe.CreditNoteParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.CreditNotes.New(p)
+}
+
+func (e *Executor) ListCreditNotes(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.CreditNoteListParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.CreditNotes.List(p)
+	return collectResults(i)
+}
+
+func (e *Executor) VoidCreditNote(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["id"].(string)
 	if !ok {
-		return nil, fmt.Errorf("price ID is required")
+		return nil, fmt.Errorf("credit note ID is required")
+	}
+	delete(params, "id")
+
+	p := &stripe.CreditNoteVoidCreditNoteParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
 	}
-	return price.Get(id, nil)
+	return sc.CreditNotes.VoidCreditNote(id, p)
 }
 
-func (e *Executor) UpdatePrice(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) UpdateProduct(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	id, ok := params["price"].(string)
+	id, ok := params["id"].(string)
 	if !ok {
-		return nil, fmt.Errorf("price ID is required")
+		return nil, fmt.Errorf("product ID is required")
 	}
-	delete(params, "price")
+	delete(params, "id")
 
-	p := &stripe.PriceParams{}
+	p := &stripe.ProductParams{}
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return price.Update(id, p)
+	return sc.Products.Update(id, p)
 }
 
-func (e *Executor) SearchCustomers(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) GetProduct(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	p := &stripe.CustomerSearchParams{}
-	if query, ok := params["query"].(string); ok {
-		p.Query = query
+	id, ok := params["id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("product ID is required")
 	}
-	i := customer.Search(p)
-	return collectResults(i)
+	delete(params, "id")
+
+	p := &stripe.ProductParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Products.Get(id, p)
 }
 
-func (e *Executor) GetCustomer(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) DeleteProduct(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	id, ok := params["customer"].(string)
+	id, ok := params["id"].(string)
 	if !ok {
-		return nil, fmt.Errorf("customer ID is required")
+		return nil, fmt.Errorf("product ID is required")
 	}
-	return customer.Get(id, nil)
+	delete(params, "id")
+
+	p := &stripe.ProductParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Products.Del(id, p)
 }
 
-func (e *Executor) ListBillingPortalConfigurations(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) SearchProducts(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	p := &stripe.BillingPortalConfigurationListParams{}
+	p := &stripe.ProductSearchParams{}
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	i := portalconfig.List(p)
+	if p.Query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	i := sc.Products.Search(p)
 	return collectResults(i)
 }
 
-func (e *Executor) CreateBillingPortalConfiguration(userID string, params map[string]interface{}) (interface{}, error) {
-	if err := e.setStripeKey(userID); err != nil {
+func (e *Executor) CreateCheckoutSession(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	p := &stripe.BillingPortalConfigurationParams{}
+	p := &stripe.CheckoutSessionParams{}
 	if err := convertToStripeParams(params, p); err != nil {
 		return nil, err
 	}
-	return portalconfig.New(p)
+	return sc.CheckoutSessions.New(p)
 }
 
-// collectResults collects all results from a list iterator
-func collectResults(i interface{}) (interface{}, error) {
-	var results []interface{}
+func (e *Executor) CreateBillingPortalSession(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
 
-	// Handle different iterator types
-	switch it := i.(type) {
-	case *customer.Iter:
-		for it.Next() {
-			results = append(results, it.Customer())
-		}
-		return results, it.Err()
-	case *product.Iter:
-		for it.Next() {
-			results = append(results, it.Product())
-		}
-		return results, it.Err()
-	case *price.Iter:
-		for it.Next() {
-			results = append(results, it.Price())
-		}
-		return results, it.Err()
-	case *customer.SearchIter:
-		for it.Next() {
-			results = append(results, it.Customer())
-		}
-		return results, it.Err()
-	case *portalconfig.Iter:
-		for it.Next() {
-			results = append(results, it.BillingPortalConfiguration())
-		}
-		return results, it.Err()
-	default:
-		return nil, fmt.Errorf("unsupported iterator type")
+	p := &stripe.BillingPortalSessionParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.BillingPortalSessions.New(p)
+}
+
+func (e *Executor) GetPrice(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["price"].(string)
+	if !ok {
+		return nil, fmt.Errorf("price ID is required")
+	}
+	delete(params, "price")
+
+	p := &stripe.PriceParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Prices.Get(id, p)
+}
+
+func (e *Executor) UpdatePrice(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["price"].(string)
+	if !ok {
+		return nil, fmt.Errorf("price ID is required")
+	}
+	delete(params, "price")
+
+	p := &stripe.PriceParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Prices.Update(id, p)
+}
+
+func (e *Executor) SearchPrices(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.PriceSearchParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	if p.Query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	i := sc.Prices.Search(p)
+	return collectResults(i)
+}
+
+func (e *Executor) SearchCustomers(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.CustomerSearchParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.Customers.Search(p)
+	return collectResults(i)
+}
+
+func (e *Executor) GetCustomer(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["customer"].(string)
+	if !ok {
+		return nil, fmt.Errorf("customer ID is required")
+	}
+	delete(params, "customer")
+
+	p := &stripe.CustomerParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Customers.Get(id, p)
+}
+
+func (e *Executor) ListBillingPortalConfigurations(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.BillingPortalConfigurationListParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.BillingPortalConfigurations.List(p)
+	return collectResults(i)
+}
+
+func (e *Executor) CreateBillingPortalConfiguration(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.BillingPortalConfigurationParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.BillingPortalConfigurations.New(p)
+}
+
+func (e *Executor) CreateTaxRate(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TaxRateParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TaxRates.New(p)
+}
+
+func (e *Executor) ListTaxRates(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TaxRateListParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.TaxRates.List(p)
+	return collectResults(i)
+}
+
+func (e *Executor) GetTaxRate(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["tax_rate"].(string)
+	if !ok {
+		return nil, fmt.Errorf("tax rate ID is required")
+	}
+	delete(params, "tax_rate")
+
+	p := &stripe.TaxRateParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TaxRates.Get(id, p)
+}
+
+func (e *Executor) UpdateTaxRate(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["tax_rate"].(string)
+	if !ok {
+		return nil, fmt.Errorf("tax rate ID is required")
+	}
+	delete(params, "tax_rate")
+
+	p := &stripe.TaxRateParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TaxRates.Update(id, p)
+}
+
+func (e *Executor) CreateShippingRate(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.ShippingRateParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.ShippingRates.New(p)
+}
+
+func (e *Executor) ListShippingRates(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.ShippingRateListParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.ShippingRates.List(p)
+	return collectResults(i)
+}
+
+func (e *Executor) GetShippingRate(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["shipping_rate_token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("shipping rate ID is required")
+	}
+	delete(params, "shipping_rate_token")
+
+	p := &stripe.ShippingRateParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.ShippingRates.Get(id, p)
+}
+
+func (e *Executor) UpdateShippingRate(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["shipping_rate_token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("shipping rate ID is required")
+	}
+	delete(params, "shipping_rate_token")
+
+	p := &stripe.ShippingRateParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.ShippingRates.Update(id, p)
+}
+
+func (e *Executor) CreateTaxCalculation(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TaxCalculationParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TaxCalculations.New(p)
+}
+
+func (e *Executor) ListTaxCalculationLineItems(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["calculation"].(string)
+	if !ok {
+		return nil, fmt.Errorf("tax calculation ID is required")
+	}
+	delete(params, "calculation")
+
+	p := &stripe.TaxCalculationListLineItemsParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	p.Calculation = stripe.String(id)
+	i := sc.TaxCalculations.ListLineItems(p)
+	return collectResults(i)
+}
+
+func (e *Executor) CreateTaxTransactionFromCalculation(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TaxTransactionCreateFromCalculationParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TaxTransactions.CreateFromCalculation(p)
+}
+
+func (e *Executor) CreateTaxTransactionReversal(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TaxTransactionCreateReversalParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TaxTransactions.CreateReversal(p)
+}
+
+func (e *Executor) GetTaxTransaction(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["transaction"].(string)
+	if !ok {
+		return nil, fmt.Errorf("tax transaction ID is required")
+	}
+	delete(params, "transaction")
+
+	p := &stripe.TaxTransactionParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TaxTransactions.Get(id, p)
+}
+
+func (e *Executor) ListTaxTransactionLineItems(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["transaction"].(string)
+	if !ok {
+		return nil, fmt.Errorf("tax transaction ID is required")
+	}
+	delete(params, "transaction")
+
+	p := &stripe.TaxTransactionListLineItemsParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	p.Transaction = stripe.String(id)
+	i := sc.TaxTransactions.ListLineItems(p)
+	return collectResults(i)
+}
+
+func (e *Executor) ListAccounts(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.AccountListParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.Accounts.List(p)
+	return collectResults(i)
+}
+
+func (e *Executor) GetAccount(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["account"].(string)
+	if !ok {
+		return nil, fmt.Errorf("account ID is required")
+	}
+	delete(params, "account")
+
+	p := &stripe.AccountParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Accounts.GetByID(id, p)
+}
+
+func (e *Executor) CreateAccount(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.AccountParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Accounts.New(p)
+}
+
+func (e *Executor) UpdateAccount(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["account"].(string)
+	if !ok {
+		return nil, fmt.Errorf("account ID is required")
+	}
+	delete(params, "account")
+
+	p := &stripe.AccountParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Accounts.Update(id, p)
+}
+
+func (e *Executor) CreateAccountLink(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.AccountLinkParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.AccountLinks.New(p)
+}
+
+func (e *Executor) CreateLoginLink(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["account"].(string)
+	if !ok {
+		return nil, fmt.Errorf("account ID is required")
+	}
+	delete(params, "account")
+
+	p := &stripe.LoginLinkParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	p.Account = stripe.String(id)
+	return sc.LoginLinks.New(p)
+}
+
+func (e *Executor) CreateTransfer(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TransferParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Transfers.New(p)
+}
+
+func (e *Executor) ListTransfers(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TransferListParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.Transfers.List(p)
+	return collectResults(i)
+}
+
+func (e *Executor) GetTransfer(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := params["transfer"].(string)
+	if !ok {
+		return nil, fmt.Errorf("transfer ID is required")
+	}
+	delete(params, "transfer")
+
+	p := &stripe.TransferParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.Transfers.Get(id, p)
+}
+
+func (e *Executor) CreateTestClock(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.requireTestMode(userID, params); err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TestHelpersTestClockParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TestHelpersTestClocks.New(p)
+}
+
+func (e *Executor) ListTestClocks(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.requireTestMode(userID, params); err != nil {
+		return nil, err
+	}
+
+	p := &stripe.TestHelpersTestClockListParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	i := sc.TestHelpersTestClocks.List(p)
+	return collectResults(i)
+}
+
+func (e *Executor) GetTestClock(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.requireTestMode(userID, params); err != nil {
+		return nil, err
+	}
+
+	id, ok := params["test_clock"].(string)
+	if !ok {
+		return nil, fmt.Errorf("test clock ID is required")
+	}
+	delete(params, "test_clock")
+
+	p := &stripe.TestHelpersTestClockParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TestHelpersTestClocks.Get(id, p)
+}
+
+func (e *Executor) DeleteTestClock(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.requireTestMode(userID, params); err != nil {
+		return nil, err
+	}
+
+	id, ok := params["test_clock"].(string)
+	if !ok {
+		return nil, fmt.Errorf("test clock ID is required")
+	}
+	delete(params, "test_clock")
+
+	p := &stripe.TestHelpersTestClockParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	return sc.TestHelpersTestClocks.Del(id, p)
+}
+
+func (e *Executor) AdvanceTestClock(userID string, params map[string]interface{}) (interface{}, error) {
+	sc, err := e.stripeClient(userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.requireTestMode(userID, params); err != nil {
+		return nil, err
+	}
+
+	id, ok := params["test_clock"].(string)
+	if !ok {
+		return nil, fmt.Errorf("test clock ID is required")
+	}
+	delete(params, "test_clock")
+
+	p := &stripe.TestHelpersTestClockAdvanceParams{}
+	if err := convertToStripeParams(params, p); err != nil {
+		return nil, err
+	}
+	if p.FrozenTime == nil {
+		return nil, fmt.Errorf("frozen_time is required")
+	}
+	return sc.TestHelpersTestClocks.Advance(id, p)
+}
+
+// listIterator is implemented by every stripe-go list and search iterator
+// through their embedded *stripe.Iter and *stripe.SearchIter
+type listIterator interface {
+	Next() bool
+	Current() interface{}
+	Err() error
+}
+
+// collectResults collects a single page of results from a list or search iterator
+func collectResults(it listIterator) (interface{}, error) {
+	var results []interface{}
+	for int64(len(results)) < MaxListItems && it.Next() {
+		results = append(results, it.Current())
 	}
+	return newListPage(it, results)
 }
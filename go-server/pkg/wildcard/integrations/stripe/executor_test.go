@@ -0,0 +1,333 @@
+package stripe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/form"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// useTestBackend points stripe-go at a local server for the duration of the test
+func useTestBackend(t *testing.T, url string) {
+	backend := stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(url),
+		MaxNetworkRetries: stripe.Int64(0),
+	})
+	stripe.SetBackend(stripe.APIBackend, backend)
+	t.Cleanup(func() { stripe.SetBackend(stripe.APIBackend, nil) })
+}
+
+// fakeKeyStore holds default keys by user ID, and named credentials by user ID
+// and name separated by a slash
+type fakeKeyStore map[string]string
+
+func (s fakeKeyStore) StripeCredential(userID, name string) (*wildcard.Credential, error) {
+	key := userID
+	if name != "" {
+		key += "/" + name
+	}
+	secret, ok := s[key]
+	if !ok {
+		return nil, fmt.Errorf("no Stripe API key found for user %s", userID)
+	}
+	return &wildcard.Credential{Name: name, Secret: secret}, nil
+}
+
+func TestTestHelpersRefusedForLiveKeys(t *testing.T) {
+	executor := NewExecutor(fakeKeyStore{
+		"live":       "sk_live_123",
+		"restricted": "rk_live_123",
+	})
+
+	tests := []struct {
+		name   string
+		userID string
+		fn     string
+		args   map[string]interface{}
+	}{
+		{name: "create test clock", userID: "live", fn: "stripe_post_test_helpers_test_clocks", args: map[string]interface{}{"frozen_time": 1700000000.0}},
+		{name: "advance test clock", userID: "live", fn: "stripe_post_test_helpers_test_clocks_test_clock_advance", args: map[string]interface{}{"test_clock": "clock_123", "frozen_time": 1700000000.0}},
+		{name: "list test clocks", userID: "restricted", fn: "stripe_get_test_helpers_test_clocks", args: map[string]interface{}{}},
+		{name: "customer on test clock", userID: "live", fn: "stripe_post_customers", args: map[string]interface{}{"test_clock": "clock_123"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executor.ExecuteFunction(tt.userID, tt.fn, tt.args)
+			assert.EqualError(t, err, "test helpers are not available with a live mode API key")
+		})
+	}
+}
+
+func TestConvertToStripeParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   string
+		target interface{}
+		want   url.Values
+	}{
+		{
+			name: "checkout session with line items",
+			args: `{
+				"mode": "payment",
+				"payment_method_types": ["card"],
+				"line_items": [{
+					"quantity": 2,
+					"tax_rates": ["txr_1"],
+					"price_data": {"currency": "eur", "unit_amount_decimal": 1250.5, "product_data": {"name": "Plan"}}
+				}],
+				"shipping_options": [{"shipping_rate": "shr_1"}],
+				"metadata": {"order": "42"}
+			}`,
+			target: &stripe.CheckoutSessionParams{},
+			want: url.Values{
+				"mode":                                           {"payment"},
+				"payment_method_types[0]":                        {"card"},
+				"line_items[0][quantity]":                        {"2"},
+				"line_items[0][tax_rates][0]":                    {"txr_1"},
+				"line_items[0][price_data][currency]":            {"eur"},
+				"line_items[0][price_data][unit_amount_decimal]": {"1250.5"},
+				"line_items[0][price_data][product_data][name]":  {"Plan"},
+				"shipping_options[0][shipping_rate]":             {"shr_1"},
+				"metadata[order]":                                {"42"},
+			},
+		},
+		{
+			name:   "payment link line items",
+			args:   `{"line_items": [{"price": "price_1", "quantity": 1}, {"price": "price_2", "quantity": 3}]}`,
+			target: &stripe.PaymentLinkParams{},
+			want: url.Values{
+				"line_items[0][price]":    {"price_1"},
+				"line_items[0][quantity]": {"1"},
+				"line_items[1][price]":    {"price_2"},
+				"line_items[1][quantity]": {"3"},
+			},
+		},
+		{
+			name:   "map of nested params",
+			args:   `{"amount_off": 1000, "currency": "usd", "currency_options": {"eur": {"amount_off": 900}}}`,
+			target: &stripe.CouponParams{},
+			want: url.Values{
+				"amount_off":                        {"1000"},
+				"currency":                          {"usd"},
+				"currency_options[eur][amount_off]": {"900"},
+			},
+		},
+		{
+			name:   "enum string type",
+			args:   `{"mandate_data": {"customer_acceptance": {"type": "offline", "offline": {}}}}`,
+			target: &stripe.SetupIntentConfirmParams{},
+			want: url.Values{
+				"mandate_data[customer_acceptance][type]": {"offline"},
+			},
+		},
+		{
+			name:   "fixed size array",
+			args:   `{"amounts": [32, 45]}`,
+			target: &stripe.PaymentSourceVerifyParams{},
+			want: url.Values{
+				"amounts[0]": {"32"},
+				"amounts[1]": {"45"},
+			},
+		},
+		{
+			name:   "integer slice",
+			args:   `{"tipping": {"usd": {"fixed_amounts": [100, 200], "smart_tip_threshold": 1000}}}`,
+			target: &stripe.TerminalConfigurationParams{},
+			want: url.Values{
+				"tipping[usd][fixed_amounts][0]":    {"100"},
+				"tipping[usd][fixed_amounts][1]":    {"200"},
+				"tipping[usd][smart_tip_threshold]": {"1000"},
+			},
+		},
+		{
+			name:   "embedded list params and range",
+			args:   `{"limit": 5, "starting_after": "prod_1", "created": {"gte": 1700000000}, "shippable": true, "url": "https://example.com"}`,
+			target: &stripe.ProductListParams{},
+			want: url.Values{
+				"limit":          {"5"},
+				"starting_after": {"prod_1"},
+				"created[gte]":   {"1700000000"},
+				"shippable":      {"true"},
+				"url":            {"https://example.com"},
+			},
+		},
+		{
+			name:   "search params",
+			args:   `{"query": "active:'true'", "limit": 10}`,
+			target: &stripe.PriceSearchParams{},
+			want: url.Values{
+				"query": {"active:'true'"},
+				"limit": {"10"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.args), &args))
+			require.NoError(t, convertToStripeParams(args, tt.target))
+
+			values := &form.Values{}
+			form.AppendTo(values, tt.target)
+			got, err := url.ParseQuery(values.Encode())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIdempotencyKeyOnlyOnPosts(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"object": "list", "data": [], "has_more": false}`))
+			return
+		}
+		w.Write([]byte(`{"id": "obj_1"}`))
+	}))
+	defer server.Close()
+	useTestBackend(t, server.URL)
+
+	executor := NewExecutor(fakeKeyStore{"user": "sk_test_123"})
+	calls := []struct {
+		fn   string
+		args map[string]interface{}
+		want string
+	}{
+		{fn: "stripe_post_customers", args: map[string]interface{}{"name": "Jane"}, want: "key-1"},
+		{fn: "stripe_post_coupons", args: map[string]interface{}{"percent_off": 10.0}, want: "key-2"},
+		{fn: "stripe_get_customers", args: map[string]interface{}{}, want: ""},
+	}
+	for i, call := range calls {
+		call.args[wildcard.ArgIdempotencyKey] = fmt.Sprintf("key-%d", i+1)
+		_, err := executor.ExecuteFunction("user", call.fn, call.args)
+		require.NoError(t, err)
+		assert.Equal(t, call.want, keys[i], call.fn)
+		assert.Equal(t, call.want != "", executor.SendsIdempotencyKey(call.fn), call.fn)
+	}
+}
+
+// credentialStore holds credentials by user ID and name separated by a slash.
+// The default credential is named default.
+type credentialStore map[string]wildcard.Credential
+
+func (s credentialStore) StripeCredential(userID, name string) (*wildcard.Credential, error) {
+	if name == "" {
+		name = "default"
+	}
+	credential, ok := s[userID+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("no Stripe credential named %q found for user %s", name, userID)
+	}
+	return &credential, nil
+}
+
+func TestNamedCredentials(t *testing.T) {
+	type request struct {
+		auth, account string
+		form          url.Values
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		requests = append(requests, request{
+			auth:    r.Header.Get("Authorization"),
+			account: r.Header.Get("Stripe-Account"),
+			form:    r.PostForm,
+		})
+		w.Write([]byte(`{"id": "cus_1", "object": "customer"}`))
+	}))
+	defer server.Close()
+	useTestBackend(t, server.URL)
+
+	executor := NewExecutor(credentialStore{
+		"user/default": {Name: "default", Secret: "sk_test_123"},
+		"user/acme":    {Name: "acme", Secret: "sk_live_platform", Account: "acct_123"},
+	})
+
+	_, err := executor.ExecuteFunction("user", "stripe_post_customers", map[string]interface{}{"name": "Jane"})
+	require.NoError(t, err)
+	assert.Equal(t, request{auth: "Bearer sk_test_123", form: url.Values{"name": {"Jane"}}}, requests[0])
+
+	_, err = executor.ExecuteFunction("user", "stripe_post_customers", map[string]interface{}{"name": "Jane", wildcard.ArgCredential: "acme"})
+	require.NoError(t, err)
+	assert.Equal(t, request{auth: "Bearer sk_live_platform", account: "acct_123", form: url.Values{"name": {"Jane"}}}, requests[1])
+
+	_, err = executor.ExecuteFunction("user", "stripe_post_customers", map[string]interface{}{
+		"name": "Jane", wildcard.ArgCredential: "acme", "stripe_account": "acct_123",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "acct_123", requests[2].account)
+
+	// A credential bound to an account cannot act on another one
+	for _, account := range []interface{}{"acct_999", ""} {
+		_, err = executor.ExecuteFunction("user", "stripe_post_customers", map[string]interface{}{
+			"name": "Jane", wildcard.ArgCredential: "acme", "stripe_account": account,
+		})
+		assert.EqualError(t, err, "credential acme can only act on its connected account acct_123")
+	}
+	_, err = executor.ExecuteFunction("user", "stripe_post_coupons", map[string]interface{}{
+		"percent_off": 10.0, wildcard.ArgCredential: "acme", "stripe_account": "acct_999",
+	})
+	assert.EqualError(t, err, "credential acme can only act on its connected account acct_123")
+
+	_, err = executor.ExecuteFunction("user", "stripe_post_test_helpers_test_clocks", map[string]interface{}{
+		"frozen_time": 1700000000.0, wildcard.ArgCredential: "acme",
+	})
+	assert.EqualError(t, err, "test helpers are not available with a live mode API key")
+
+	_, err = executor.ExecuteFunction("user", "stripe_get_customers", map[string]interface{}{wildcard.ArgCredential: "missing"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no Stripe credential named "missing"`)
+	assert.Len(t, requests, 3)
+}
+
+func TestConcurrentUsersKeepTheirKeys(t *testing.T) {
+	var mu sync.Mutex
+	auths := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		mu.Lock()
+		auths[r.Form.Get("name")] = append(auths[r.Form.Get("name")], r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Write([]byte(`{"id": "obj_1"}`))
+	}))
+	defer server.Close()
+	useTestBackend(t, server.URL)
+
+	executor := NewExecutor(fakeKeyStore{"alice": "sk_test_alice", "bob": "sk_test_bob"})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, user := range []string{"alice", "bob"} {
+			wg.Add(1)
+			go func(user string) {
+				defer wg.Done()
+				// A hand-written method and a generic operation
+				_, err := executor.ExecuteFunction(user, "stripe_post_customers", map[string]interface{}{"name": user})
+				assert.NoError(t, err)
+				_, err = executor.ExecuteFunction(user, "stripe_post_coupons", map[string]interface{}{"name": user, "percent_off": 10.0})
+				assert.NoError(t, err)
+			}(user)
+		}
+	}
+	wg.Wait()
+
+	for _, user := range []string{"alice", "bob"} {
+		require.Len(t, auths[user], 40)
+		for _, auth := range auths[user] {
+			assert.Equal(t, "Bearer sk_test_"+user, auth)
+		}
+	}
+}
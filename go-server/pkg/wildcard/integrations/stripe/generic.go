@@ -0,0 +1,111 @@
+package stripe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/form"
+	"github.com/stripe/stripe-go/v81/rawrequest"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// executeOperation runs a Stripe operation from the OpenAPI spec through the raw backend
+// with the given API key. Path parameters are taken from the arguments and the rest are
+// form-encoded.
+func executeOperation(op Operation, args map[string]interface{}, key string) (interface{}, error) {
+	path := op.Path
+	remaining := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		remaining[k] = v
+	}
+
+	for _, name := range op.PathParams {
+		value, ok := remaining[name].(string)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("path parameter %s is required", name)
+		}
+		path = strings.Replace(path, "{"+name+"}", url.PathEscape(value), 1)
+		delete(remaining, name)
+	}
+
+	params := &stripe.RawParams{}
+	if account, ok := remaining["stripe_account"].(string); ok && account != "" {
+		params.SetStripeAccount(account)
+	}
+	delete(remaining, "stripe_account")
+	if key, ok := remaining[wildcard.ArgIdempotencyKey].(string); ok && key != "" {
+		params.SetIdempotencyKey(key)
+	}
+	delete(remaining, wildcard.ArgIdempotencyKey)
+	delete(remaining, wildcard.ArgCredential)
+
+	if limit, ok := remaining["limit"].(float64); ok && int64(limit) > MaxListItems {
+		remaining["limit"] = float64(MaxListItems)
+	}
+
+	body := encodeForm(remaining)
+	if op.Method != http.MethodPost && body != "" {
+		path += "?" + body
+		body = ""
+	}
+
+	backend, ok := stripe.GetBackend(stripe.APIBackend).(stripe.RawRequestBackend)
+	if !ok {
+		return nil, fmt.Errorf("the Stripe API backend does not support raw requests")
+	}
+	resp, err := rawrequest.Client{B: backend, Key: key}.RawRequest(op.Method, path, body, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(resp.RawJSON, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode Stripe response: %w", err)
+	}
+	return result, nil
+}
+
+// encodeForm encodes arguments using Stripe's bracketed form conventions
+func encodeForm(args map[string]interface{}) string {
+	values := &form.Values{}
+	appendFormValue(values, nil, args)
+	return values.Encode()
+}
+
+func appendFormValue(values *form.Values, keyParts []string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			appendFormValue(values, append(keyParts, k), v[k])
+		}
+	case []interface{}:
+		for i, item := range v {
+			appendFormValue(values, append(keyParts, strconv.Itoa(i)), item)
+		}
+	case string:
+		values.Add(form.FormatKey(keyParts), v)
+	case bool:
+		values.Add(form.FormatKey(keyParts), strconv.FormatBool(v))
+	case float64:
+		values.Add(form.FormatKey(keyParts), strconv.FormatFloat(v, 'f', -1, 64))
+	case int:
+		values.Add(form.FormatKey(keyParts), strconv.Itoa(v))
+	case int64:
+		values.Add(form.FormatKey(keyParts), strconv.FormatInt(v, 10))
+	case nil:
+		values.Add(form.FormatKey(keyParts), "")
+	default:
+		values.Add(form.FormatKey(keyParts), fmt.Sprintf("%v", v))
+	}
+}
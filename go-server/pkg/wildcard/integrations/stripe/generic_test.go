@@ -0,0 +1,106 @@
+package stripe
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionMapMatchesSpec(t *testing.T) {
+	ops, err := Operations()
+	require.NoError(t, err)
+
+	for name := range FunctionMap {
+		assert.Contains(t, ops, name)
+	}
+}
+
+func TestParseSpec3Schemas(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "spec3_sample.json"))
+	require.NoError(t, err)
+	ops, err := parseOperations(data)
+	require.NoError(t, err)
+
+	create := ops["stripe_post_customers"]
+	assert.True(t, create.Closed)
+	properties := create.Arguments["properties"].(map[string]interface{})
+	assert.Contains(t, properties, "email")
+	shipping := properties["shipping"].(map[string]interface{})
+	assert.Equal(t, "object", shipping["type"], "component references are inlined")
+	assert.Equal(t, []interface{}{"line1"}, shipping["required"])
+
+	// Recursive references are cut off
+	owner := properties["owner"].(map[string]interface{})
+	for depth := 0; owner["properties"] != nil; depth++ {
+		require.Less(t, depth, maxSchemaDepth+1)
+		next := owner["properties"].(map[string]interface{})["address"].(map[string]interface{})
+		owner = next["properties"].(map[string]interface{})["customer"].(map[string]interface{})
+	}
+	assert.Equal(t, map[string]interface{}{"type": "object"}, owner)
+
+	retrieve := ops["stripe_get_customers_customer"]
+	assert.False(t, retrieve.Closed)
+	assert.Equal(t, []string{"customer"}, retrieve.Required)
+}
+
+func TestOperationName(t *testing.T) {
+	assert.Equal(t, "stripe_post_invoices_invoice_finalize", OperationName("POST", "/v1/invoices/{invoice}/finalize"))
+	assert.Equal(t, "stripe_get_billing_portal_configurations", OperationName("get", "/v1/billing_portal/configurations"))
+}
+
+func TestExecuteOperation(t *testing.T) {
+	var method, path, account string
+	var body url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		account = r.Header.Get("Stripe-Account")
+		raw, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost {
+			body, _ = url.ParseQuery(string(raw))
+		} else {
+			body = r.URL.Query()
+		}
+		w.Write([]byte(`{"id": "sub_123", "object": "subscription"}`))
+	}))
+	defer server.Close()
+	useTestBackend(t, server.URL)
+
+	executor := NewExecutor(fakeKeyStore{"user": "sk_test_123"})
+
+	result, err := executor.ExecuteFunction("user", "stripe_post_subscriptions_subscription_exposed_id", map[string]interface{}{
+		"subscription_exposed_id": "sub_123",
+		"stripe_account":          "acct_123",
+		"cancel_at_period_end":    true,
+		"items": []interface{}{
+			map[string]interface{}{"price": "price_123", "quantity": 2.0},
+		},
+		"metadata": map[string]interface{}{"order": "42"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "sub_123", "object": "subscription"}, result)
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "/v1/subscriptions/sub_123", path)
+	assert.Equal(t, "acct_123", account)
+	assert.Equal(t, url.Values{
+		"cancel_at_period_end": {"true"},
+		"items[0][price]":      {"price_123"},
+		"items[0][quantity]":   {"2"},
+		"metadata[order]":      {"42"},
+	}, body)
+
+	_, err = executor.ExecuteFunction("user", "stripe_get_subscriptions", map[string]interface{}{"limit": 3.0})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodGet, method)
+	assert.Equal(t, url.Values{"limit": {"3"}}, body)
+
+	_, err = executor.ExecuteFunction("user", "stripe_delete_coupons_coupon", map[string]interface{}{})
+	assert.EqualError(t, err, "invalid arguments: missing required fields: coupon")
+}
@@ -0,0 +1,235 @@
+package stripe
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// specJSON is a curated subset of the Stripe API in OpenAPI 3 form. It is not
+// Stripe's spec3.json, which cannot be vendored from this tree: it lists the
+// method, path and required parameters of each supported operation, but has
+// no component schemas and describes no request body properties. The parser
+// reads the full spec3.json format, component schemas included, so replacing
+// this file with spec3.json exposes and validates every Stripe operation.
+//
+//go:embed openapi/curated.json
+var specJSON []byte
+
+// maxSchemaDepth bounds how deeply component schemas are inlined. Stripe's
+// schemas refer to each other recursively; deeper references become plain
+// objects.
+const maxSchemaDepth = 6
+
+// Operation describes how a Stripe OpenAPI operation maps to an HTTP request
+type Operation struct {
+	Method      string
+	Path        string
+	PathParams  []string
+	Required    []string // Required arguments, including path parameters
+	Description string
+	Arguments   map[string]interface{} // JSON schema of the arguments, from parameters and request body
+	Closed      bool                   // Arguments lists every accepted argument, so others are rejected
+}
+
+// openAPISpec holds the parts of an OpenAPI 3 document needed to route requests
+type openAPISpec struct {
+	Paths map[string]map[string]struct {
+		OperationID string `json:"operationId"`
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+		Parameters  []struct {
+			Name        string                 `json:"name"`
+			In          string                 `json:"in"`
+			Required    bool                   `json:"required"`
+			Description string                 `json:"description"`
+			Schema      map[string]interface{} `json:"schema"`
+		} `json:"parameters"`
+		RequestBody struct {
+			Content map[string]struct {
+				Schema map[string]interface{} `json:"schema"`
+			} `json:"content"`
+		} `json:"requestBody"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+var (
+	operationsOnce sync.Once
+	operations     map[string]Operation
+	operationsErr  error
+)
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// Operations returns the operations in the embedded Stripe spec, keyed by Wildcard function name
+func Operations() (map[string]Operation, error) {
+	operationsOnce.Do(func() {
+		operations, operationsErr = parseOperations(specJSON)
+	})
+	return operations, operationsErr
+}
+
+// parseOperations reads an OpenAPI 3 document into operations keyed by Wildcard function name
+func parseOperations(data []byte) (map[string]Operation, error) {
+	var spec openAPISpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse Stripe OpenAPI spec: %w", err)
+	}
+
+	ops := make(map[string]Operation)
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			method = strings.ToUpper(method)
+			if method != http.MethodGet && method != http.MethodPost && method != http.MethodDelete {
+				continue
+			}
+
+			var pathParams, required []string
+			properties := make(map[string]interface{})
+			for _, param := range op.Parameters {
+				if param.In == "path" {
+					pathParams = append(pathParams, param.Name)
+				} else if param.In == "query" && param.Required {
+					required = append(required, param.Name)
+				}
+				if param.In == "path" || param.In == "query" {
+					properties[param.Name] = parameterSchema(spec.resolve(param.Schema, 0), param.Description)
+				}
+			}
+			if len(pathParams) == 0 {
+				for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+					pathParams = append(pathParams, match[1])
+				}
+			}
+			closed := false
+			for _, content := range op.RequestBody.Content {
+				body := spec.resolve(content.Schema, 0)
+				required = append(required, stringList(body["required"])...)
+				bodyProperties, _ := body["properties"].(map[string]interface{})
+				for name, schema := range bodyProperties {
+					properties[name] = schema
+				}
+				closed = body["additionalProperties"] == false
+			}
+
+			required = append(pathParams[:len(pathParams):len(pathParams)], required...)
+			for _, name := range required {
+				if _, ok := properties[name]; !ok {
+					properties[name] = map[string]interface{}{"type": "string"}
+				}
+			}
+			arguments := map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+			}
+			if len(required) > 0 {
+				arguments["required"] = required
+			}
+
+			description := op.Summary
+			if description == "" {
+				description = op.Description
+			}
+			if description == "" {
+				description = method + " " + path
+			}
+
+			ops[OperationName(method, path)] = Operation{
+				Method:      method,
+				Path:        path,
+				PathParams:  pathParams,
+				Required:    required,
+				Description: description,
+				Arguments:   arguments,
+				Closed:      closed,
+			}
+		}
+	}
+	return ops, nil
+}
+
+// resolve returns a copy of schema with its component references inlined
+func (spec *openAPISpec) resolve(schema map[string]interface{}, depth int) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		component, exists := spec.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+		if !exists || depth >= maxSchemaDepth {
+			return map[string]interface{}{"type": "object"}
+		}
+		return spec.resolve(component, depth+1)
+	}
+
+	resolved := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if k == "properties" {
+				properties := make(map[string]interface{}, len(v))
+				for name, property := range v {
+					if property, ok := property.(map[string]interface{}); ok {
+						properties[name] = spec.resolve(property, depth)
+					}
+				}
+				resolved[k] = properties
+			} else {
+				resolved[k] = spec.resolve(v, depth)
+			}
+		case []interface{}:
+			items := make([]interface{}, len(v))
+			for i, item := range v {
+				items[i] = item
+				if itemSchema, ok := item.(map[string]interface{}); ok {
+					items[i] = spec.resolve(itemSchema, depth)
+				}
+			}
+			resolved[k] = items
+		default:
+			resolved[k] = v
+		}
+	}
+	return resolved
+}
+
+// stringList converts a decoded JSON list of strings
+func stringList(value interface{}) []string {
+	list, _ := value.([]interface{})
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// parameterSchema returns the schema of a parameter with its description
+func parameterSchema(schema map[string]interface{}, description string) map[string]interface{} {
+	result := map[string]interface{}{"type": "string"}
+	if schema != nil {
+		result = make(map[string]interface{}, len(schema)+1)
+		for k, v := range schema {
+			result[k] = v
+		}
+	}
+	if description != "" {
+		result["description"] = description
+	}
+	return result
+}
+
+// OperationName returns the Wildcard function name for a Stripe method and path,
+// e.g. POST /v1/invoices/{invoice}/finalize becomes stripe_post_invoices_invoice_finalize
+func OperationName(method, path string) string {
+	path = strings.TrimPrefix(path, "/v1/")
+	path = strings.NewReplacer("{", "", "}", "", "/", "_").Replace(path)
+	return fmt.Sprintf("stripe_%s_%s", strings.ToLower(method), path)
+}
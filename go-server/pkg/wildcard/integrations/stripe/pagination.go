@@ -0,0 +1,88 @@
+package stripe
+
+import (
+	"reflect"
+
+	"github.com/stripe/stripe-go/v81"
+)
+
+// MaxListItems caps the number of items a single list or search call returns.
+// Stripe accepts page sizes up to 100.
+var MaxListItems int64 = 100
+
+// ListPage is a single page of list or search results. NextCursor is passed
+// back as starting_after (ending_before when paging backwards) for lists, or
+// as page for searches.
+type ListPage struct {
+	Object     string        `json:"object"`
+	Data       []interface{} `json:"data"`
+	HasMore    bool          `json:"has_more"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// limitListPage restricts a list call to one page of at most MaxListItems items
+func limitListPage(p *stripe.ListParams) {
+	p.Single = true
+	if p.Limit == nil || *p.Limit > MaxListItems || *p.Limit < 1 {
+		p.Limit = stripe.Int64(MaxListItems)
+	}
+}
+
+// limitSearchPage restricts a search call to one page of at most MaxListItems
+// items, like limitListPage. What the agent is shown of the page is limited
+// when the result is shaped.
+func limitSearchPage(p *stripe.SearchParams) {
+	p.Single = true
+	if p.Limit == nil || *p.Limit > MaxListItems || *p.Limit < 1 {
+		p.Limit = stripe.Int64(MaxListItems)
+	}
+}
+
+// newListPage wraps the items read from an iterator with its paging state
+func newListPage(i listIterator, results []interface{}) (*ListPage, error) {
+	if err := i.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &ListPage{
+		Object: "list",
+		Data:   results,
+	}
+	if page.Data == nil {
+		page.Data = []interface{}{}
+	}
+
+	switch it := i.(type) {
+	case interface{ Meta() *stripe.ListMeta }:
+		if meta := it.Meta(); meta != nil {
+			page.HasMore = meta.HasMore
+		}
+		if page.HasMore && len(results) > 0 {
+			page.NextCursor = listItemID(results[len(results)-1])
+		}
+	case interface{ Meta() *stripe.SearchMeta }:
+		page.Object = "search_result"
+		if meta := it.Meta(); meta != nil {
+			page.HasMore = meta.HasMore
+			if meta.HasMore && meta.NextPage != nil {
+				page.NextCursor = *meta.NextPage
+			}
+		}
+	}
+	return page, nil
+}
+
+// listItemID returns the ID of a Stripe object, used as the cursor for the next page
+func listItemID(item interface{}) string {
+	v := reflect.ValueOf(item)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if id := v.FieldByName("ID"); id.IsValid() && id.Kind() == reflect.String {
+		return id.String()
+	}
+	return ""
+}
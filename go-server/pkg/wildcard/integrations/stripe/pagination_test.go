@@ -0,0 +1,80 @@
+package stripe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/form"
+)
+
+func TestListPagination(t *testing.T) {
+	var requests []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query())
+		if r.URL.Path == "/v1/customers/search" {
+			w.Write([]byte(`{"object": "search_result", "data": [{"id": "cus_3"}], "has_more": true, "next_page": "page_2"}`))
+			return
+		}
+		w.Write([]byte(`{"object": "list", "data": [{"id": "cus_1"}, {"id": "cus_2"}], "has_more": true}`))
+	}))
+	defer server.Close()
+	useTestBackend(t, server.URL)
+
+	executor := NewExecutor(fakeKeyStore{"user": "sk_test_123"})
+
+	result, err := executor.ExecuteFunction("user", "stripe_get_customers", map[string]interface{}{
+		"limit":          2.0,
+		"starting_after": "cus_0",
+	})
+	require.NoError(t, err)
+	page := result.(*ListPage)
+	assert.Equal(t, "list", page.Object)
+	assert.Len(t, page.Data, 2)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, "cus_2", page.NextCursor)
+	require.Len(t, requests, 1, "only a single page should be fetched")
+	assert.Equal(t, "2", requests[0].Get("limit"))
+	assert.Equal(t, "cus_0", requests[0].Get("starting_after"))
+
+	requests = nil
+	_, err = executor.ExecuteFunction("user", "stripe_get_customers", map[string]interface{}{"limit": 1000.0})
+	require.NoError(t, err)
+	assert.Equal(t, "100", requests[0].Get("limit"))
+
+	requests = nil
+	result, err = executor.ExecuteFunction("user", "stripe_get_customers_search", map[string]interface{}{"query": "email:'a@b.c'"})
+	require.NoError(t, err)
+	page = result.(*ListPage)
+	assert.Equal(t, "search_result", page.Object)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, "page_2", page.NextCursor)
+	require.Len(t, requests, 1)
+}
+
+func TestCollectResultsAnyIterator(t *testing.T) {
+	// Invoices have no hand-written list method, so this exercises an iterator
+	// type the executor never names
+	calls := 0
+	params := &stripe.InvoiceListParams{}
+	limitListPage(&params.ListParams)
+	it := stripe.GetIter(params, func(p *stripe.Params, b *form.Values) ([]interface{}, stripe.ListContainer, error) {
+		calls++
+		list := &stripe.InvoiceList{ListMeta: stripe.ListMeta{HasMore: true}}
+		list.Data = []*stripe.Invoice{{ID: "in_1"}, {ID: "in_2"}}
+		return []interface{}{list.Data[0], list.Data[1]}, list, nil
+	})
+
+	result, err := collectResults(it)
+	require.NoError(t, err)
+
+	page := result.(*ListPage)
+	assert.Len(t, page.Data, 2)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, "in_2", page.NextCursor)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,149 @@
+package stripe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/stripe/stripe-go/v81"
+)
+
+// MaxRetries is the number of times the executor retries a retryable Stripe error
+var MaxRetries = 3
+
+// Backoff before the first retry, doubling with each further attempt up to retryMaxDelay
+var (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+)
+
+// sleep is replaced in tests
+var sleep = time.Sleep
+
+// APIError is a Stripe API error with the details Wildcard needs to correct or abandon the call
+type APIError struct {
+	Err      *stripe.Error
+	Attempts int
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("stripe %s: %s", e.Err.Type, e.Err.Msg)
+	if e.Err.Code != "" {
+		msg += fmt.Sprintf(" (code: %s)", e.Err.Code)
+	}
+	if e.Err.Param != "" {
+		msg += fmt.Sprintf(" (param: %s)", e.Err.Param)
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Details returns the structured fields of the Stripe error
+func (e *APIError) Details() map[string]interface{} {
+	details := map[string]interface{}{
+		"type":      string(e.Err.Type),
+		"message":   e.Err.Msg,
+		"status":    e.Err.HTTPStatusCode,
+		"retryable": isRetryable(e.Err),
+		"attempts":  e.Attempts,
+	}
+	if e.Err.Code != "" {
+		details["code"] = string(e.Err.Code)
+	}
+	if e.Err.Param != "" {
+		details["param"] = e.Err.Param
+	}
+	if e.Err.DeclineCode != "" {
+		details["decline_code"] = string(e.Err.DeclineCode)
+	}
+	if e.Err.RequestID != "" {
+		details["request_id"] = e.Err.RequestID
+	}
+	return details
+}
+
+// isRetryable reports whether a failed call may succeed if repeated: rate limits,
+// lock timeouts, Stripe server errors and connection failures
+func isRetryable(err error) bool {
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) {
+		switch {
+		case stripeErr.HTTPStatusCode == http.StatusTooManyRequests:
+			return true
+		case stripeErr.Code == stripe.ErrorCodeLockTimeout || stripeErr.Code == stripe.ErrorCodeRateLimit:
+			return true
+		case stripeErr.Type == stripe.ErrorTypeAPI, stripeErr.HTTPStatusCode >= 500:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// withRetry runs call, retrying retryable errors with jittered exponential backoff.
+// Each attempt gets its own copy of the arguments since methods consume ID keys.
+func withRetry(args map[string]interface{}, call func(map[string]interface{}) (interface{}, error)) (interface{}, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		attemptArgs := make(map[string]interface{}, len(args))
+		for k, v := range args {
+			attemptArgs[k] = v
+		}
+
+		var result interface{}
+		result, err = call(attemptArgs)
+		if err == nil {
+			return result, nil
+		}
+		if attempt > MaxRetries || !isRetryable(err) {
+			return nil, wrapError(err, attempt)
+		}
+		sleep(backoff(attempt))
+	}
+}
+
+// backoff returns a jittered delay before the given retry
+func backoff(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	return delay/2 + time.Duration(mathrand.Int63n(int64(delay/2)+1))
+}
+
+// wrapError attaches the attempt count and structured details to a failed call
+func wrapError(err error, attempts int) error {
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) {
+		return &APIError{Err: stripeErr, Attempts: attempts}
+	}
+	if attempts > 1 {
+		return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+	}
+	return err
+}
+
+// randReader is the source of generated idempotency keys
+var randReader io.Reader = rand.Reader
+
+// newIdempotencyKey generates a key for mutating calls that were not given one,
+// so that retries of the call cannot duplicate it. Without randomness the call
+// fails rather than share a key that Stripe would deduplicate against.
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(randReader, b); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key: %v", err)
+	}
+	return "retry-" + hex.EncodeToString(b), nil
+}
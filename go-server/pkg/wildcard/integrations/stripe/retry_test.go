@@ -0,0 +1,129 @@
+package stripe
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+func TestRetryRateLimit(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"type": "invalid_request_error", "code": "rate_limit", "message": "Too many requests"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "re_1", "object": "refund"}`))
+	}))
+	defer server.Close()
+	useTestBackend(t, server.URL)
+
+	executor := NewExecutor(fakeKeyStore{"user": "sk_test_123"})
+	result, err := executor.ExecuteFunction("user", "stripe_post_refunds", map[string]interface{}{"charge": "ch_1"})
+	require.NoError(t, err)
+	assert.NotNil(t, result)
+	require.Len(t, keys, 3)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1], "retries must reuse the idempotency key")
+	assert.Equal(t, keys[0], keys[2], "retries must reuse the idempotency key")
+}
+
+func TestStructuredStripeErrors(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Request-Id", "req_123")
+		w.WriteHeader(http.StatusPaymentRequired)
+		w.Write([]byte(`{"error": {"type": "card_error", "code": "card_declined", "decline_code": "insufficient_funds", "param": "source", "message": "Your card has insufficient funds."}}`))
+	}))
+	defer server.Close()
+	useTestBackend(t, server.URL)
+
+	executor := NewExecutor(fakeKeyStore{"user": "sk_test_123"})
+	_, err := executor.ExecuteFunction("user", "stripe_post_refunds", map[string]interface{}{"charge": "ch_1"})
+	require.Error(t, err)
+	assert.Equal(t, 1, requests, "card errors must not be retried")
+
+	var detailed wildcard.DetailedError
+	require.True(t, errors.As(err, &detailed))
+	assert.Equal(t, map[string]interface{}{
+		"type":         "card_error",
+		"code":         "card_declined",
+		"decline_code": "insufficient_funds",
+		"param":        "source",
+		"message":      "Your card has insufficient funds.",
+		"request_id":   "req_123",
+		"status":       http.StatusPaymentRequired,
+		"retryable":    false,
+		"attempts":     1,
+	}, detailed.Details())
+	assert.Equal(t, "stripe card_error: Your card has insufficient funds. (code: card_declined) (param: source)", err.Error())
+}
+
+// redirectTransport sends every request to a local server
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestRetriesAreNotStacked(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error": {"type": "api_error", "message": "Service unavailable"}}`))
+	}))
+	defer server.Close()
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	UseHTTPClient(&http.Client{Transport: redirectTransport{target: target}})
+	t.Cleanup(func() { UseHTTPClient(nil) })
+
+	executor := NewExecutor(fakeKeyStore{"user": "sk_test_123"})
+	_, err = executor.ExecuteFunction("user", "stripe_post_refunds", map[string]interface{}{"charge": "ch_1"})
+	require.Error(t, err)
+	assert.Equal(t, MaxRetries+1, requests, "stripe-go must not retry on top of the executor")
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("entropy unavailable")
+}
+
+func TestIdempotencyKeyNeedsRandomness(t *testing.T) {
+	key, err := newIdempotencyKey()
+	require.NoError(t, err)
+	assert.Regexp(t, "^retry-[0-9a-f]{32}$", key)
+
+	randReader = failingReader{}
+	t.Cleanup(func() { randReader = rand.Reader })
+	executor := NewExecutor(fakeKeyStore{"user": "sk_test_123"})
+	_, err = executor.ExecuteFunction("user", "stripe_post_customers", map[string]interface{}{"name": "Jane"})
+	assert.EqualError(t, err, "failed to generate idempotency key: entropy unavailable")
+}
@@ -0,0 +1,219 @@
+package stripe
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// MaxShapedListItems caps the number of list items passed back to the agent.
+// The full page is still returned to the caller of ExecuteFunction.
+var MaxShapedListItems = 10
+
+// keyFields are kept from every Stripe object when shaping a result
+var keyFields = []string{
+	"id", "object", "status", "deleted", "livemode", "created", "type",
+	"amount", "amount_due", "amount_paid", "amount_remaining", "amount_total",
+	"amount_refunded", "amount_captured", "amount_received", "amount_subtotal",
+	"unit_amount", "unit_amount_decimal", "currency", "total", "subtotal",
+	"url", "hosted_invoice_url", "invoice_pdf", "receipt_url", "return_url",
+	"customer", "product", "price", "invoice", "charge", "payment_intent",
+	"subscription", "account", "destination",
+}
+
+// objectFields are kept in addition to keyFields for specific Stripe objects
+var objectFields = map[string][]string{
+	"product":                 {"name", "active", "default_price", "description"},
+	"price":                   {"active", "nickname", "lookup_key", "recurring.interval", "recurring.interval_count"},
+	"customer":                {"description", "balance", "test_clock"},
+	"invoice":                 {"number", "due_date", "paid", "collection_method"},
+	"subscription":            {"cancel_at_period_end", "current_period_end", "items.data.price.id"},
+	"payment_link":            {"active"},
+	"coupon":                  {"name", "percent_off", "amount_off", "duration", "valid"},
+	"promotion_code":          {"code", "active", "coupon.id"},
+	"refund":                  {"reason"},
+	"credit_note":             {"number", "reason"},
+	"tax_rate":                {"display_name", "percentage", "inclusive", "jurisdiction", "active"},
+	"shipping_rate":           {"display_name", "active", "fixed_amount.amount", "fixed_amount.currency"},
+	"tax.calculation":         {"tax_amount_exclusive", "tax_amount_inclusive"},
+	"tax.transaction":         {"reference"},
+	"test_helpers.test_clock": {"name", "frozen_time"},
+	"account":                 {"charges_enabled", "payouts_enabled", "details_submitted", "country"},
+	"account_link":            {"expires_at"},
+	"transfer":                {"reversed"},
+	"balance":                 {"available", "pending"},
+}
+
+// functionFields override the shaping of functions whose results are not
+// described well by their object type
+var functionFields = map[string][]string{
+	"stripe_get_balance": {"object", "available", "pending"},
+}
+
+// ShapeResult reduces a function result to the fields the agent needs for its
+// next step: IDs, amounts, status and URLs, plus a few fields per object type.
+// Lists are truncated to MaxShapedListItems with a count of the omitted items.
+// When fields is set, only those fields (dotted paths) and the object ID and type are kept.
+func (e *Executor) ShapeResult(name string, result interface{}, fields []string) interface{} {
+	value, ok := toJSONValue(result)
+	if !ok {
+		return result
+	}
+	if fields == nil {
+		fields = functionFields[name]
+	}
+	return shapeValue(value, fields)
+}
+
+// toJSONValue converts a result into its generic JSON form
+func toJSONValue(result interface{}) (interface{}, bool) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, false
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// shapeValue shapes a list page or a single object
+func shapeValue(value interface{}, fields []string) interface{} {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	if items, ok := obj["data"].([]interface{}); ok && isList(obj["object"]) {
+		return shapeList(obj, items, fields)
+	}
+	return shapeObject(obj, fields)
+}
+
+func isList(object interface{}) bool {
+	return object == "list" || object == "search_result"
+}
+
+// shapeList shapes the first MaxShapedListItems items of a list and summarizes the rest.
+// A truncated list page continues after the last item kept, so that paging on
+// from the shaped result does not skip the omitted items. Search pages continue
+// from an opaque page token instead, so for them the omitted count is all the
+// agent is told about the items it was not shown.
+func shapeList(list map[string]interface{}, items []interface{}, fields []string) map[string]interface{} {
+	shaped := map[string]interface{}{
+		"object": list["object"],
+		"count":  len(items),
+	}
+	for _, key := range []string{"has_more", "next_cursor"} {
+		if v, ok := list[key]; ok {
+			shaped[key] = v
+		}
+	}
+
+	limit := len(items)
+	if limit > MaxShapedListItems {
+		limit = MaxShapedListItems
+		shaped["omitted"] = len(items) - limit
+		if last, ok := items[limit-1].(map[string]interface{}); ok && list["object"] == "list" {
+			if id, ok := last["id"].(string); ok && id != "" {
+				shaped["has_more"] = true
+				shaped["next_cursor"] = id
+			}
+		}
+	}
+	data := make([]interface{}, 0, limit)
+	for _, item := range items[:limit] {
+		data = append(data, shapeValue(item, fields))
+	}
+	shaped["data"] = data
+	return shaped
+}
+
+// shapeObject keeps the key fields of an object, or the requested fields. The
+// object type is always kept since PII redaction of fields such as a customer's
+// name depends on it.
+func shapeObject(obj map[string]interface{}, fields []string) map[string]interface{} {
+	shaped := make(map[string]interface{})
+	if fields != nil {
+		copyField(shaped, obj, "id")
+		copyObjectType(shaped, obj)
+		for _, field := range fields {
+			copyPath(shaped, obj, strings.Split(field, "."))
+		}
+		return shaped
+	}
+
+	for _, field := range keyFields {
+		copyField(shaped, obj, field)
+	}
+	object, _ := obj["object"].(string)
+	for _, field := range objectFields[object] {
+		copyPath(shaped, obj, strings.Split(field, "."))
+	}
+	return shaped
+}
+
+// copyField copies a top-level field, reducing expanded objects to their ID
+func copyField(dst, src map[string]interface{}, field string) {
+	v, ok := src[field]
+	if !ok || v == nil {
+		return
+	}
+	if nested, ok := v.(map[string]interface{}); ok {
+		if id, ok := nested["id"]; ok && field != "id" {
+			v = id
+		}
+	}
+	dst[field] = v
+}
+
+// copyObjectType copies the object field of a Stripe object
+func copyObjectType(dst, src map[string]interface{}) {
+	if object, ok := src["object"].(string); ok && object != "" {
+		dst["object"] = object
+	}
+}
+
+// copyPath copies a dotted path from src to dst. Lists along the path are kept
+// as lists of the selected sub-fields.
+func copyPath(dst, src map[string]interface{}, path []string) {
+	v, ok := src[path[0]]
+	if !ok || v == nil {
+		return
+	}
+	if len(path) == 1 {
+		copyField(dst, src, path[0])
+		return
+	}
+
+	switch nested := v.(type) {
+	case map[string]interface{}:
+		sub, _ := dst[path[0]].(map[string]interface{})
+		if sub == nil {
+			sub = make(map[string]interface{})
+			copyObjectType(sub, nested)
+			dst[path[0]] = sub
+		}
+		copyPath(sub, nested, path[1:])
+	case []interface{}:
+		existing, _ := dst[path[0]].([]interface{})
+		items := make([]interface{}, len(nested))
+		for i, item := range nested {
+			itemMap, ok := item.(map[string]interface{})
+			if !ok {
+				items[i] = item
+				continue
+			}
+			var sub map[string]interface{}
+			if i < len(existing) {
+				sub, _ = existing[i].(map[string]interface{})
+			}
+			if sub == nil {
+				sub = make(map[string]interface{})
+				copyObjectType(sub, itemMap)
+			}
+			copyPath(sub, itemMap, path[1:])
+			items[i] = sub
+		}
+		dst[path[0]] = items
+	}
+}
@@ -0,0 +1,76 @@
+package stripe
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+func TestShapeResult(t *testing.T) {
+	executor := NewExecutor(fakeKeyStore{})
+
+	customer := &stripe.Customer{
+		ID:          "cus_123",
+		Object:      "customer",
+		Email:       "jane@example.com",
+		Name:        "Jane Doe",
+		Description: "VIP",
+		Metadata:    map[string]string{"order": "42"},
+	}
+	shaped := executor.ShapeResult("stripe_get_customers_customer", customer, nil).(map[string]interface{})
+	assert.Equal(t, "cus_123", shaped["id"])
+	assert.Equal(t, "VIP", shaped["description"])
+	assert.NotContains(t, shaped, "email")
+	assert.NotContains(t, shaped, "metadata")
+
+	shaped = executor.ShapeResult("stripe_get_customers_customer", customer, []string{"email", "metadata.order"}).(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"id":       "cus_123",
+		"object":   "customer",
+		"email":    "jane@example.com",
+		"metadata": map[string]interface{}{"order": "42"},
+	}, shaped)
+
+	// Requested fields keep the object type, so that scoped PII fields are still redacted
+	shaped = executor.ShapeResult("stripe_get_customers_customer", customer, []string{"name"}).(map[string]interface{})
+	redacted := wildcard.NewRedactor(nil).NewRedaction().Redact(shaped).(map[string]interface{})
+	assert.Equal(t, "[NAME_1]", redacted["name"])
+
+	subscription := &stripe.Subscription{
+		ID:       "sub_123",
+		Object:   "subscription",
+		Status:   stripe.SubscriptionStatusActive,
+		Customer: &stripe.Customer{ID: "cus_123", Email: "jane@example.com"},
+		Items: &stripe.SubscriptionItemList{Data: []*stripe.SubscriptionItem{
+			{ID: "si_1", Price: &stripe.Price{ID: "price_1", UnitAmount: 500}},
+		}},
+	}
+	shaped = executor.ShapeResult("stripe_get_subscriptions_subscription_exposed_id", subscription, nil).(map[string]interface{})
+	assert.Equal(t, "cus_123", shaped["customer"], "expanded objects are reduced to their ID")
+	assert.Equal(t, "active", shaped["status"])
+	assert.Equal(t, map[string]interface{}{
+		"data": []interface{}{map[string]interface{}{"price": map[string]interface{}{"id": "price_1"}}},
+	}, shaped["items"])
+
+	var items []interface{}
+	for i := 0; i < MaxShapedListItems+5; i++ {
+		items = append(items, &stripe.Product{ID: fmt.Sprintf("prod_%d", i), Object: "product", Name: "Shirt"})
+	}
+	page := &ListPage{Object: "list", Data: items, HasMore: true, NextCursor: "prod_14"}
+	shaped = executor.ShapeResult("stripe_get_products", page, nil).(map[string]interface{})
+	assert.Equal(t, len(items), shaped["count"])
+	assert.Equal(t, 5, shaped["omitted"])
+	assert.Equal(t, true, shaped["has_more"])
+	assert.Equal(t, "prod_9", shaped["next_cursor"], "paging resumes after the last item shown")
+	page = &ListPage{Object: "list", Data: items}
+	shaped = executor.ShapeResult("stripe_get_products", page, nil).(map[string]interface{})
+	assert.Equal(t, true, shaped["has_more"], "the omitted items are still to be paged")
+	assert.Equal(t, "prod_9", shaped["next_cursor"])
+	assert.Len(t, shaped["data"], MaxShapedListItems)
+	first := shaped["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "prod_0", first["id"])
+	assert.Equal(t, "Shirt", first["name"])
+}
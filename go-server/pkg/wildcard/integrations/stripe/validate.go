@@ -0,0 +1,438 @@
+package stripe
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// ValidationError lists every problem found in the arguments of a function call
+// so that Wildcard can correct them in a single step
+type ValidationError struct {
+	Missing    []string
+	Unknown    []string
+	Mismatched []string
+}
+
+func (e *ValidationError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing required fields: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Unknown) > 0 {
+		problems = append(problems, "unknown fields: "+strings.Join(e.Unknown, ", "))
+	}
+	if len(e.Mismatched) > 0 {
+		problems = append(problems, "type mismatches: "+strings.Join(e.Mismatched, "; "))
+	}
+	return "invalid arguments: " + strings.Join(problems, "; ")
+}
+
+// Details returns the problems as structured fields
+func (e *ValidationError) Details() map[string]interface{} {
+	details := map[string]interface{}{"type": "validation_error"}
+	if len(e.Missing) > 0 {
+		details["missing"] = e.Missing
+	}
+	if len(e.Unknown) > 0 {
+		details["unknown"] = e.Unknown
+	}
+	if len(e.Mismatched) > 0 {
+		details["mismatched"] = e.Mismatched
+	}
+	return details
+}
+
+func (e *ValidationError) empty() bool {
+	return len(e.Missing) == 0 && len(e.Unknown) == 0 && len(e.Mismatched) == 0
+}
+
+// validateRequired checks that the operation's required arguments are present
+func validateRequired(op Operation, args map[string]interface{}) error {
+	verr := &ValidationError{}
+	for _, name := range op.Required {
+		if value, ok := args[name]; !ok || value == nil || value == "" {
+			verr.Missing = append(verr.Missing, name)
+		}
+	}
+	if verr.empty() {
+		return nil
+	}
+	return verr
+}
+
+// validateOperation checks the arguments of a generic call against the
+// operation's schema: required arguments must be present, known arguments must
+// match their schema, and a closed operation rejects unknown arguments
+func validateOperation(op Operation, args map[string]interface{}) error {
+	verr := &ValidationError{}
+	for _, name := range op.Required {
+		if value, ok := args[name]; !ok || value == nil || value == "" {
+			verr.Missing = append(verr.Missing, name)
+		}
+	}
+
+	properties, _ := op.Arguments["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "stripe_account" || key == wildcard.ArgIdempotencyKey || key == wildcard.ArgCredential || args[key] == nil {
+			continue
+		}
+		schema, ok := properties[key].(map[string]interface{})
+		if !ok {
+			if op.Closed {
+				verr.Unknown = append(verr.Unknown, key)
+			}
+			continue
+		}
+		if !validateSchemaValue(args[key], schema, key, verr) {
+			verr.Mismatched = append(verr.Mismatched, fmt.Sprintf("%s: expected %s, got %s",
+				key, describeSchema(schema), describeValue(args[key])))
+		}
+	}
+
+	if verr.empty() {
+		return nil
+	}
+	return verr
+}
+
+// validateSchemaValue reports whether value matches a JSON schema. Nested
+// problems inside a matching object or list are recorded on verr.
+func validateSchemaValue(value interface{}, schema map[string]interface{}, path string, verr *ValidationError) bool {
+	if alternatives, ok := schema["anyOf"].([]interface{}); ok {
+		for _, alternative := range alternatives {
+			alternative, ok := alternative.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			// Alternatives are tried on a scratch error so that a failed one
+			// does not report its nested problems
+			scratch := &ValidationError{}
+			if validateSchemaValue(value, alternative, path, scratch) {
+				verr.Missing = append(verr.Missing, scratch.Missing...)
+				verr.Unknown = append(verr.Unknown, scratch.Unknown...)
+				verr.Mismatched = append(verr.Mismatched, scratch.Mismatched...)
+				return true
+			}
+		}
+		return false
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, allowed := range enum {
+			if value == allowed {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch schema["type"] {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, item := range arr {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if items != nil && !validateSchemaValue(item, items, itemPath, verr) {
+				verr.Mismatched = append(verr.Mismatched, fmt.Sprintf("%s: expected %s, got %s",
+					itemPath, describeSchema(items), describeValue(item)))
+			}
+		}
+		return true
+	case "object":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			itemPath := path + "." + k
+			property, ok := properties[k].(map[string]interface{})
+			if !ok {
+				if properties != nil && schema["additionalProperties"] == false {
+					verr.Unknown = append(verr.Unknown, itemPath)
+				}
+				continue
+			}
+			if m[k] != nil && !validateSchemaValue(m[k], property, itemPath, verr) {
+				verr.Mismatched = append(verr.Mismatched, fmt.Sprintf("%s: expected %s, got %s",
+					itemPath, describeSchema(property), describeValue(m[k])))
+			}
+		}
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := m[name]; !ok {
+				verr.Missing = append(verr.Missing, path+"."+name)
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// describeSchema names the type of a JSON schema in the terms of the arguments
+func describeSchema(schema map[string]interface{}) string {
+	if alternatives, ok := schema["anyOf"].([]interface{}); ok {
+		names := make([]string, 0, len(alternatives))
+		for _, alternative := range alternatives {
+			if alternative, ok := alternative.(map[string]interface{}); ok {
+				names = append(names, describeSchema(alternative))
+			}
+		}
+		return strings.Join(names, " or ")
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		values := make([]string, len(enum))
+		for i, v := range enum {
+			values[i] = fmt.Sprintf("%q", v)
+		}
+		return "one of " + strings.Join(values, ", ")
+	}
+	if schema["type"] == "array" {
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			return "array of " + describeSchema(items)
+		}
+	}
+	if t, ok := schema["type"].(string); ok {
+		return t
+	}
+	return "any value"
+}
+
+// validateParams checks arguments against the form fields of a Stripe params struct
+func validateParams(params map[string]interface{}, target interface{}) error {
+	verr := &ValidationError{}
+	validateStruct(params, reflect.TypeOf(target).Elem(), "", verr)
+	if verr.empty() {
+		return nil
+	}
+	return verr
+}
+
+func validateStruct(params map[string]interface{}, t reflect.Type, prefix string, verr *ValidationError) {
+	fields := formFields(t)
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := params[key]
+		if prefix == "" && (key == "stripe_account" || key == wildcard.ArgIdempotencyKey || key == wildcard.ArgCredential) {
+			continue
+		}
+
+		types, ok := fields[key]
+		if !ok {
+			verr.Unknown = append(verr.Unknown, prefix+key)
+			continue
+		}
+		if value == nil {
+			continue
+		}
+
+		matched := false
+		for _, ft := range types {
+			if validateValue(value, ft, prefix+key, verr) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			expected := make([]string, len(types))
+			for i, ft := range types {
+				expected[i] = describeType(ft)
+			}
+			verr.Mismatched = append(verr.Mismatched, fmt.Sprintf("%s: expected %s, got %s",
+				prefix+key, strings.Join(expected, " or "), describeValue(value)))
+		}
+	}
+}
+
+// formFields returns the types of a params struct's fields keyed by form name,
+// including fields of embedded structs flattened into the form
+func formFields(t reflect.Type) map[string][]reflect.Type {
+	fields := make(map[string][]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		formName := strings.Split(field.Tag.Get("form"), ",")[0]
+		switch formName {
+		case "", "-":
+			continue
+		case "*":
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() != reflect.Struct {
+				continue
+			}
+			for name, types := range formFields(embedded) {
+				if _, ok := fields[name]; !ok {
+					fields[name] = types
+				}
+			}
+		default:
+			fields[formName] = append(fields[formName], field.Type)
+		}
+	}
+	return fields
+}
+
+// validateValue reports whether value can be converted to t. Nested problems
+// inside a matching object or list are recorded on verr.
+func validateValue(value interface{}, t reflect.Type, path string, verr *ValidationError) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		_, ok := value.(string)
+		return ok
+	case reflect.Bool:
+		_, ok := value.(bool)
+		return ok
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		}
+		return false
+	case reflect.Float32, reflect.Float64:
+		switch value.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	case reflect.Map:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			itemPath := path + "." + k
+			if !validateValue(m[k], t.Elem(), itemPath, verr) {
+				verr.Mismatched = append(verr.Mismatched, fmt.Sprintf("%s: expected %s, got %s",
+					itemPath, describeType(t.Elem()), describeValue(m[k])))
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Array:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		if t.Kind() == reflect.Array && len(arr) > t.Len() {
+			verr.Mismatched = append(verr.Mismatched, fmt.Sprintf("%s: expected at most %d items, got %d", path, t.Len(), len(arr)))
+			return true
+		}
+		for i, item := range arr {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if !validateValue(item, t.Elem(), itemPath, verr) {
+				verr.Mismatched = append(verr.Mismatched, fmt.Sprintf("%s: expected %s, got %s",
+					itemPath, describeType(t.Elem()), describeValue(item)))
+			}
+		}
+		return true
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		validateStruct(m, t, path+".", verr)
+		return true
+	}
+	return true
+}
+
+// describeType names a params field type in the terms of the JSON arguments
+func describeType(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array of " + describeType(t.Elem())
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return t.String()
+}
+
+// describeValue names the JSON type of an argument value
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64:
+		return "integer"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,139 @@
+package stripe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v81"
+)
+
+func TestValidateParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		target  interface{}
+		wantErr string
+	}{
+		{
+			name: "valid product list",
+			params: map[string]interface{}{
+				"active":         true,
+				"ids":            []interface{}{"prod_1"},
+				"created":        map[string]interface{}{"gte": 1700000000.0},
+				"limit":          10.0,
+				"stripe_account": "acct_123",
+			},
+			target: &stripe.ProductListParams{},
+		},
+		{
+			name:    "unknown field",
+			params:  map[string]interface{}{"nmae": "Premium"},
+			target:  &stripe.ProductParams{},
+			wantErr: "invalid arguments: unknown fields: nmae",
+		},
+		{
+			name:    "string where integer expected",
+			params:  map[string]interface{}{"unit_amount": "1000", "currency": "usd"},
+			target:  &stripe.PriceParams{},
+			wantErr: "invalid arguments: type mismatches: unit_amount: expected integer, got string",
+		},
+		{
+			name:    "fractional integer",
+			params:  map[string]interface{}{"unit_amount": 10.5},
+			target:  &stripe.PriceParams{},
+			wantErr: "invalid arguments: type mismatches: unit_amount: expected integer, got number",
+		},
+		{
+			name:    "range or timestamp",
+			params:  map[string]interface{}{"created": "yesterday"},
+			target:  &stripe.ProductListParams{},
+			wantErr: "invalid arguments: type mismatches: created: expected integer or object, got string",
+		},
+		{
+			name: "nested problems",
+			params: map[string]interface{}{
+				"line_items": []interface{}{
+					map[string]interface{}{"price": "price_1", "qty": 1.0},
+					"price_2",
+				},
+				"metadata": map[string]interface{}{"order": 42.0},
+				"mode":     "payment",
+			},
+			target:  &stripe.CheckoutSessionParams{},
+			wantErr: "invalid arguments: unknown fields: line_items[0].qty; type mismatches: line_items[1]: expected object, got string; metadata.order: expected string, got integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateParams(tt.params, tt.target)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	ops, err := Operations()
+	assert.NoError(t, err)
+
+	err = validateRequired(ops["stripe_post_tax_rates_tax_rate"], map[string]interface{}{"active": false})
+	assert.EqualError(t, err, "invalid arguments: missing required fields: tax_rate")
+
+	err = validateRequired(ops["stripe_post_tax_rates"], map[string]interface{}{"display_name": "VAT"})
+	assert.EqualError(t, err, "invalid arguments: missing required fields: inclusive, percentage")
+
+	err = validateRequired(ops["stripe_get_products_search"], map[string]interface{}{"query": "active:'true'"})
+	assert.NoError(t, err)
+}
+
+func TestValidateOperation(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "spec3_sample.json"))
+	require.NoError(t, err)
+	ops, err := parseOperations(data)
+	require.NoError(t, err)
+	create := ops["stripe_post_customers"]
+
+	assert.NoError(t, validateOperation(create, map[string]interface{}{
+		"email":          "jane@example.com",
+		"balance":        1500.0,
+		"expand":         []interface{}{"tax"},
+		"metadata":       map[string]interface{}{"order": "123"},
+		"shipping":       map[string]interface{}{"line1": "1 Main St"},
+		"tax_exempt":     "none",
+		"stripe_account": "acct_123",
+	}))
+	assert.NoError(t, validateOperation(create, map[string]interface{}{"metadata": ""}), "any alternative is accepted")
+
+	err = validateOperation(create, map[string]interface{}{
+		"balance":    "lots",
+		"nickname":   "Jane",
+		"expand":     []interface{}{"tax", 2.0},
+		"shipping":   map[string]interface{}{"city": "Paris", "zip": "75001"},
+		"tax_exempt": "sometimes",
+		"metadata":   1.0,
+	})
+	require.Error(t, err)
+	assert.Equal(t, &ValidationError{
+		Missing: []string{"shipping.line1"},
+		Unknown: []string{"nickname", "shipping.zip"},
+		Mismatched: []string{
+			"balance: expected integer, got string",
+			"expand[1]: expected string, got integer",
+			"metadata: expected object or one of \"\", got integer",
+			"tax_exempt: expected one of \"\", \"exempt\", \"none\", \"reverse\", got string",
+		},
+	}, err)
+
+	// Operations without a closed schema accept arguments they do not list
+	retrieve := ops["stripe_get_customers_customer"]
+	assert.NoError(t, validateOperation(retrieve, map[string]interface{}{"customer": "cus_123", "limit": 3.0}))
+	err = validateOperation(retrieve, map[string]interface{}{"expand": "tax"})
+	assert.EqualError(t, err, "invalid arguments: missing required fields: customer; type mismatches: expand: expected array of string, got string")
+}
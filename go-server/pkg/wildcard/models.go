@@ -9,9 +9,15 @@ type Response struct {
 
 // APIResponse represents a standardized API response
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success        bool                   `json:"success"`
+	Data           interface{}            `json:"data,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+	ErrorDetails   map[string]interface{} `json:"error_details,omitempty"`
+	IdempotencyKey string                 `json:"idempotency_key,omitempty"`
+
+	// Shaped is the reduced form of Data passed back to Wildcard, set when the
+	// executor implements ResultShaper
+	Shaped interface{} `json:"-"`
 }
 
 // SessionResponse represents the response from creating a new session
@@ -26,6 +32,14 @@ type Function struct {
 	Arguments map[string]interface{} `json:"arguments"`
 }
 
+// FunctionInfo describes a function offered by an executor
+type FunctionInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Arguments   map[string]interface{} `json:"arguments"` // JSON schema of the arguments
+	ReadOnly    bool                   `json:"read_only"` // Whether the function only reads data
+}
+
 // Event types for Wildcard responses
 const (
 	EventExec  = "EXEC"  // Execute a function
@@ -33,7 +47,20 @@ const (
 	EventError = "ERROR" // Error occurred
 )
 
+// ArgIdempotencyKey is the reserved argument carrying the idempotency key of a function call
+const ArgIdempotencyKey = "idempotency_key"
+
+// ArgResponseFields is the reserved argument listing the result fields Wildcard
+// wants back from a function call. It is not passed to the executor.
+const ArgResponseFields = "response_fields"
+
+// ArgCredential is the reserved argument naming the credential a function call
+// should use. Without it the user's default credential for the integration is used.
+const ArgCredential = "credential"
+
 // API names for different integrations
 const (
 	APINameStripe = "stripe" // Stripe API integration
+	APINameGitHub = "github" // GitHub API integration
+	APINameSlack  = "slack"  // Slack API integration
 )
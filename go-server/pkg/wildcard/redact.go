@@ -0,0 +1,209 @@
+package wildcard
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultPIIFields are the result fields redacted when no fields are configured.
+// A field given as "object.field" only applies to objects of that type.
+var DefaultPIIFields = []string{
+	"email",
+	"phone",
+	"address",
+	"shipping",
+	"billing_details",
+	"receipt_email",
+	"customer_email",
+	"customer_name",
+	"customer_phone",
+	"customer_address",
+	"customer_shipping",
+	"customer_details",
+	"customer.name",
+	"account.individual",
+}
+
+// Redactor describes which result fields contain PII
+type Redactor struct {
+	fields  map[string]bool
+	objects map[string]map[string]bool
+}
+
+// NewRedactor creates a redactor for the given fields, or DefaultPIIFields if none are given
+func NewRedactor(fields []string) *Redactor {
+	if len(fields) == 0 {
+		fields = DefaultPIIFields
+	}
+	r := &Redactor{
+		fields:  make(map[string]bool),
+		objects: make(map[string]map[string]bool),
+	}
+	for _, field := range fields {
+		object, name, scoped := strings.Cut(field, ".")
+		if !scoped {
+			r.fields[field] = true
+			continue
+		}
+		if r.objects[object] == nil {
+			r.objects[object] = make(map[string]bool)
+		}
+		r.objects[object][name] = true
+	}
+	return r
+}
+
+// NewRedaction starts the redaction of a run. Placeholders are consistent for
+// the lifetime of the returned Redaction. A nil Redactor returns a nil
+// Redaction, which leaves values unchanged.
+func (r *Redactor) NewRedaction() *Redaction {
+	if r == nil {
+		return nil
+	}
+	return &Redaction{
+		redactor:     r,
+		placeholders: make(map[string]string),
+		values:       make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+// Redaction tokenizes PII within a single run and restores it afterwards
+type Redaction struct {
+	redactor     *Redactor
+	placeholders map[string]string // label and value to placeholder
+	values       map[string]string // placeholder to value
+	counts       map[string]int
+}
+
+// Redact returns a copy of value in its JSON form with PII fields replaced by placeholders
+func (r *Redaction) Redact(value interface{}) interface{} {
+	if r == nil || value == nil {
+		return value
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return value
+	}
+	return r.redactValue(generic, "")
+}
+
+// redactValue walks a JSON value. Label is set once inside a PII field, and every
+// string below it is tokenized under that label.
+func (r *Redaction) redactValue(value interface{}, label string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object, _ := v["object"].(string)
+		redacted := make(map[string]interface{}, len(v))
+		// Sorted keys keep placeholder numbering deterministic
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			item := v[key]
+			itemLabel := label
+			if itemLabel == "" && (r.redactor.fields[key] || r.redactor.objects[object][key]) {
+				itemLabel = key
+			}
+			redacted[key] = r.redactValue(item, itemLabel)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = r.redactValue(item, label)
+		}
+		return redacted
+	case string:
+		if label == "" || v == "" {
+			return v
+		}
+		return r.placeholder(label, v)
+	default:
+		return v
+	}
+}
+
+// placeholder returns the placeholder for a value, reusing it if the value was seen before
+func (r *Redaction) placeholder(label, value string) string {
+	key := label + "\x00" + value
+	if placeholder, ok := r.placeholders[key]; ok {
+		return placeholder
+	}
+	label = strings.ToUpper(label)
+	r.counts[label]++
+	placeholder := fmt.Sprintf("[%s_%d]", label, r.counts[label])
+	r.placeholders[key] = placeholder
+	r.values[placeholder] = value
+	return placeholder
+}
+
+// RedactText replaces the PII values seen so far in the run with their
+// placeholders, for text such as error messages that has no field structure
+func (r *Redaction) RedactText(text string) string {
+	if r == nil || len(r.values) == 0 {
+		return text
+	}
+	placeholders := make([]string, 0, len(r.values))
+	for placeholder := range r.values {
+		placeholders = append(placeholders, placeholder)
+	}
+	// Longer values first, so that a value is not replaced inside a longer one
+	sort.Slice(placeholders, func(i, j int) bool {
+		a, b := r.values[placeholders[i]], r.values[placeholders[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return placeholders[i] < placeholders[j]
+	})
+	pairs := make([]string, 0, len(placeholders)*2)
+	for _, placeholder := range placeholders {
+		pairs = append(pairs, r.values[placeholder], placeholder)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Rehydrate replaces the placeholders in text with the original values
+func (r *Redaction) Rehydrate(text string) string {
+	if r == nil || len(r.values) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, len(r.values)*2)
+	for placeholder, value := range r.values {
+		pairs = append(pairs, placeholder, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// RehydrateValue replaces the placeholders in every string of a JSON-like value
+func (r *Redaction) RehydrateValue(value interface{}) interface{} {
+	if r == nil || len(r.values) == 0 {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		rehydrated := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rehydrated[key] = r.RehydrateValue(item)
+		}
+		return rehydrated
+	case []interface{}:
+		rehydrated := make([]interface{}, len(v))
+		for i, item := range v {
+			rehydrated[i] = r.RehydrateValue(item)
+		}
+		return rehydrated
+	case string:
+		return r.Rehydrate(v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,68 @@
+package wildcard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedaction(t *testing.T) {
+	redaction := NewRedactor(nil).NewRedaction()
+
+	customer := map[string]interface{}{
+		"id":     "cus_123",
+		"object": "customer",
+		"name":   "Jane Doe",
+		"email":  "jane@example.com",
+		"address": map[string]interface{}{
+			"line1": "1 Main St",
+			"city":  "Springfield",
+		},
+	}
+	redacted := redaction.Redact(customer).(map[string]interface{})
+	assert.Equal(t, "cus_123", redacted["id"])
+	assert.Equal(t, "[NAME_1]", redacted["name"])
+	assert.Equal(t, "[EMAIL_1]", redacted["email"])
+	assert.Equal(t, map[string]interface{}{"line1": "[ADDRESS_2]", "city": "[ADDRESS_1]"}, redacted["address"])
+	assert.Equal(t, "jane@example.com", customer["email"], "the original result must not be modified")
+
+	// The same value keeps its placeholder for the whole run, and names of other
+	// objects are not PII
+	invoice := redaction.Redact(map[string]interface{}{
+		"object":         "invoice",
+		"customer_email": "jane@example.com",
+		"lines":          []interface{}{map[string]interface{}{"object": "product", "name": "Shirt"}},
+	}).(map[string]interface{})
+	assert.Equal(t, "[CUSTOMER_EMAIL_1]", invoice["customer_email"])
+	assert.Equal(t, "Shirt", invoice["lines"].([]interface{})[0].(map[string]interface{})["name"])
+	again := redaction.Redact(customer).(map[string]interface{})
+	assert.Equal(t, "[EMAIL_1]", again["email"])
+
+	assert.Equal(t, "Created Jane Doe (jane@example.com) at 1 Main St",
+		redaction.Rehydrate("Created [NAME_1] ([EMAIL_1]) at [ADDRESS_2]"))
+	assert.Equal(t, map[string]interface{}{"to": []interface{}{"jane@example.com"}},
+		redaction.RehydrateValue(map[string]interface{}{"to": []interface{}{"[EMAIL_1]"}}))
+
+	assert.Equal(t, "No such customer named [NAME_1] at [ADDRESS_2]",
+		redaction.RedactText("No such customer named Jane Doe at 1 Main St"))
+
+	var disabled *Redaction
+	assert.Equal(t, customer, disabled.Redact(customer))
+	assert.Equal(t, "[EMAIL_1]", disabled.Rehydrate("[EMAIL_1]"))
+	assert.Equal(t, "jane@example.com", disabled.RedactText("jane@example.com"))
+}
+
+func TestFailureMessageRedaction(t *testing.T) {
+	redaction := NewRedactor(nil).NewRedaction()
+	redaction.Redact(map[string]interface{}{"object": "customer", "email": "jane@example.com"})
+
+	resp := &APIResponse{
+		Error: "We tried to execute function 'send', but received error: invalid recipient jane@example.com",
+		ErrorDetails: map[string]interface{}{
+			"status": 422,
+			"body":   map[string]interface{}{"email": "jane@example.com", "phone": "+15555550100"},
+		},
+	}
+	assert.Equal(t, `We tried to execute function 'send', but received error: invalid recipient [EMAIL_1]. Error details: {"body":{"email":"[EMAIL_1]","phone":"[PHONE_1]"},"status":422}`,
+		resp.FailureMessage(redaction))
+}
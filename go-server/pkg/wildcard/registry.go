@@ -0,0 +1,57 @@
+package wildcard
+
+import (
+	"sort"
+	"sync"
+)
+
+// Registry holds the executors of the registered integrations, keyed by API name
+type Registry struct {
+	mu        sync.RWMutex
+	executors map[string]Executor
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		executors: make(map[string]Executor),
+	}
+}
+
+// Register registers the executor of an API, replacing any previous one
+func (r *Registry) Register(apiName string, executor Executor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.executors[apiName] = executor
+}
+
+// Executor returns the executor registered for an API
+func (r *Registry) Executor(apiName string) (Executor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	executor, ok := r.executors[apiName]
+	return executor, ok
+}
+
+// APIs returns the names of the registered APIs, sorted
+func (r *Registry) APIs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	apis := make([]string, 0, len(r.executors))
+	for api := range r.executors {
+		apis = append(apis, api)
+	}
+	sort.Strings(apis)
+	return apis
+}
+
+// Catalog returns the functions offered by the executor of an API, sorted by name
+func (r *Registry) Catalog(apiName string) ([]FunctionInfo, bool) {
+	executor, ok := r.Executor(apiName)
+	if !ok {
+		return nil, false
+	}
+	catalog := append([]FunctionInfo(nil), executor.Catalog()...)
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+	return catalog, true
+}
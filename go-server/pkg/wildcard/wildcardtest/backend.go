@@ -0,0 +1,172 @@
+// Package wildcardtest provides a fake Wildcard backend for tests and local
+// development. It implements the session and process endpoints used by
+// wildcard.Client and answers each message with the next response of a script.
+package wildcardtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+// Message is a message received by the backend
+type Message struct {
+	UserID    string
+	SessionID string
+	Message   string
+}
+
+// Backend is a fake Wildcard backend. Every session plays the script from the
+// start, one response per message. A session that runs past the end of the
+// script receives an ERROR event.
+type Backend struct {
+	mu       sync.Mutex
+	script   []wildcard.Response
+	sessions map[string]int // session ID -> next step
+	messages []Message
+}
+
+// NewBackend creates a backend that plays the given script
+func NewBackend(script ...wildcard.Response) *Backend {
+	return &Backend{
+		script:   script,
+		sessions: make(map[string]int),
+	}
+}
+
+// LoadScript reads a script from a JSON file containing a list of Wildcard responses
+func LoadScript(path string) ([]wildcard.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	var script []wildcard.Response
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to decode script: %w", err)
+	}
+	return script, nil
+}
+
+// Exec returns an EXEC event calling a Stripe function
+func Exec(name string, arguments map[string]interface{}) wildcard.Response {
+	return ExecAPI(wildcard.APINameStripe, name, arguments)
+}
+
+// ExecAPI returns an EXEC event calling a function of the given API
+func ExecAPI(api, name string, arguments map[string]interface{}) wildcard.Response {
+	if arguments == nil {
+		arguments = map[string]interface{}{}
+	}
+	return wildcard.Response{
+		Event: wildcard.EventExec,
+		API:   api,
+		Data: map[string]interface{}{
+			"name":      name,
+			"arguments": arguments,
+		},
+	}
+}
+
+// Stop returns a STOP event with the given data
+func Stop(data map[string]interface{}) wildcard.Response {
+	return wildcard.Response{
+		Event: wildcard.EventStop,
+		Data:  data,
+	}
+}
+
+// Error returns an ERROR event with the given message
+func Error(message string) wildcard.Response {
+	return wildcard.Response{
+		Event: wildcard.EventError,
+		Data:  map[string]interface{}{"message": message},
+	}
+}
+
+// Messages returns the messages received so far, in order
+func (b *Backend) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.messages...)
+}
+
+// ServeHTTP implements POST /session/{user} and POST /process/{user}/{session}
+func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "session":
+		b.createSession(w)
+	case len(parts) == 3 && parts[0] == "process":
+		b.process(w, r, parts[1], parts[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (b *Backend) createSession(w http.ResponseWriter) {
+	b.mu.Lock()
+	sessionID := fmt.Sprintf("session-%d", len(b.sessions)+1)
+	b.sessions[sessionID] = 0
+	b.mu.Unlock()
+
+	writeJSON(w, wildcard.SessionResponse{SessionID: sessionID})
+}
+
+func (b *Backend) process(w http.ResponseWriter, r *http.Request, userID, sessionID string) {
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b.mu.Lock()
+	step, ok := b.sessions[sessionID]
+	if !ok {
+		b.mu.Unlock()
+		http.Error(w, fmt.Sprintf("unknown session %s", sessionID), http.StatusNotFound)
+		return
+	}
+	b.sessions[sessionID] = step + 1
+	b.messages = append(b.messages, Message{UserID: userID, SessionID: sessionID, Message: req.Message})
+	b.mu.Unlock()
+
+	if step >= len(b.script) {
+		writeJSON(w, Error("script exhausted"))
+		return
+	}
+	writeJSON(w, b.script[step])
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Server is a Backend served over HTTP on a local port
+type Server struct {
+	*httptest.Server
+	Backend *Backend
+}
+
+// NewServer starts a fake Wildcard backend playing the given script. Pass
+// Server.URL as the Wildcard base URL, and Close the server when done.
+func NewServer(script ...wildcard.Response) *Server {
+	backend := NewBackend(script...)
+	return &Server{
+		Server:  httptest.NewServer(backend),
+		Backend: backend,
+	}
+}
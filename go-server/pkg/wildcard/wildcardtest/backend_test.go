@@ -0,0 +1,106 @@
+package wildcardtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wildcard-lovable/go-server/pkg/wildcard"
+)
+
+type echoExecutor struct {
+	calls []string
+}
+
+func (e *echoExecutor) ExecuteFunction(userID string, name string, arguments map[string]interface{}) (interface{}, error) {
+	e.calls = append(e.calls, name)
+	return map[string]interface{}{"id": "cus_123", "object": "customer"}, nil
+}
+
+func (e *echoExecutor) Catalog() []wildcard.FunctionInfo {
+	return []wildcard.FunctionInfo{{Name: "stripe_get_customers", ReadOnly: true}}
+}
+
+func TestClientAgainstBackend(t *testing.T) {
+	server := NewServer(
+		Exec("stripe_get_customers", map[string]interface{}{"limit": 1}),
+		Stop(map[string]interface{}{"message": "Found one customer"}),
+	)
+	defer server.Close()
+
+	executor := &echoExecutor{}
+	client := wildcard.NewClient(server.URL)
+	client.RegisterExecutor(wildcard.APINameStripe, executor)
+
+	resp, err := client.ProcessAPIMessage("user123", wildcard.APINameStripe, "list my customers")
+	require.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Equal(t, map[string]interface{}{"message": "Found one customer"}, resp.Data)
+	assert.Equal(t, []string{"stripe_get_customers"}, executor.calls)
+
+	messages := server.Backend.Messages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, Message{UserID: "user123", SessionID: "session-1", Message: "list my customers"}, messages[0])
+	assert.Contains(t, messages[1].Message, "cus_123")
+
+	// A new session plays the script from the start
+	sessionID, err := client.CreateSession("user123")
+	require.NoError(t, err)
+	assert.Equal(t, "session-2", sessionID)
+	next, err := client.ProcessMessage("user123", sessionID, "again")
+	require.NoError(t, err)
+	assert.Equal(t, wildcard.EventExec, next.Event)
+}
+
+func TestClientRejectsOtherAPIs(t *testing.T) {
+	server := NewServer(
+		ExecAPI(wildcard.APINameGitHub, "github_delete_repo", map[string]interface{}{"repo": "app"}),
+		Stop(map[string]interface{}{"message": "Done"}),
+	)
+	defer server.Close()
+
+	stripeExecutor, githubExecutor := &echoExecutor{}, &echoExecutor{}
+	client := wildcard.NewClient(server.URL)
+	client.RegisterExecutor(wildcard.APINameStripe, stripeExecutor)
+	client.RegisterExecutor(wildcard.APINameGitHub, githubExecutor)
+
+	resp, err := client.ProcessAPIMessage("user123", wildcard.APINameStripe, "list my customers")
+	require.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Empty(t, githubExecutor.calls, "functions of other integrations must not run")
+
+	messages := server.Backend.Messages()
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[1].Message, "can only use the stripe integration")
+}
+
+func TestBackendScriptExhausted(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	client := wildcard.NewClient(server.URL)
+	sessionID, err := client.CreateSession("user123")
+	require.NoError(t, err)
+
+	resp, err := client.ProcessMessage("user123", sessionID, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, wildcard.EventError, resp.Event)
+	assert.Equal(t, "script exhausted", resp.Data["message"])
+
+	_, err = client.ProcessMessage("user123", "missing", "hello")
+	assert.Error(t, err, "unknown sessions are rejected")
+}
+
+func TestLoadScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"event": "EXEC", "api": "stripe", "data": {"name": "stripe_get_balance", "arguments": {}}},
+		{"event": "STOP", "data": {"message": "Done"}}
+	]`), 0o600))
+
+	script, err := LoadScript(path)
+	require.NoError(t, err)
+	assert.Equal(t, []wildcard.Response{Exec("stripe_get_balance", nil), Stop(map[string]interface{}{"message": "Done"})}, script)
+}